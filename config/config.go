@@ -1,33 +1,109 @@
 package config
 
 import (
+	_ "embed"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port               string
-	DBHost             string
-	DBPort             string
-	DBUser             string
-	DBPassword         string
-	DBName             string
-	JWTSecret          string
-	JWTExpirationHours int
-	SMTPHost           string
-	SMTPPort           int
-	SMTPUsername       string
-	SMTPPassword       string
-	SMTPFrom           string
-	FrontendURL        string
-	GinMode            string
+	Port                         string
+	DBHost                       string
+	DBPort                       string
+	DBUser                       string
+	DBPassword                   string
+	DBName                       string
+	JWTSecret                    string
+	JWTAlgorithm                 string
+	JWTKeyID                     string
+	JWTPrivateKeyFile            string
+	JWTPublicKeys                string
+	JWTAllowEphemeralKey         bool
+	AccessTokenMinutes           int
+	RefreshTokenDays             int
+	TokenPurgeMinutes            int
+	SMTPHost                     string
+	SMTPPort                     int
+	SMTPUsername                 string
+	SMTPPassword                 string
+	SMTPFrom                     string
+	FrontendURL                  string
+	GinMode                      string
+	TrustedProxies               []string
+	AdminEmails                  []string
+	AppName                      string
+	MFAChallengeMinutes          int
+	LoginMaxAttempts             int
+	LoginIPMaxAttempts           int
+	LoginLockoutMinutes          int
+	RateLimitEnabled             bool
+	PasswordResetTokenMinutes    int
+	EmailVerificationTokenHours  int
+	PasswordHashAlgorithm        string
+	Argon2MemoryKiB              int
+	Argon2Iterations             int
+	Argon2Parallelism            int
+	BcryptCost                   int
+	PasswordMinLength            int
+	PasswordMaxLength            int
+	PasswordRequireUppercase     bool
+	PasswordRequireLowercase     bool
+	PasswordRequireDigit         bool
+	PasswordRequireSymbol        bool
+	PasswordDisallowPersonalInfo bool
+	PasswordMinEntropyBits       int
+	PasswordBlocklistFile        string
+	PasswordHistorySize          int
+	MagicLinkMinutes             int
+	EmailVerificationMode        string
+	PasswordResetMode            string
+	PasswordlessLoginMode        string
+	EmailCodeMinutes             int
+	EmailCodeMaxAttempts         int
+	WebAuthnRPID                 string
+	WebAuthnRPName               string
+	WebAuthnOrigins              []string
+	WebAuthnChallengeMinutes     int
+	OAuthStateMinutes            int
+	IdentityProviders            []IdentityProviderConfig
+	OAuthIssuer                  string
+	OAuthServerScopes            []string
+	OAuthCodeMinutes             int
+	OAuthDeviceCodeMinutes       int
+	OAuthDevicePollSeconds       int
+	OrgInvitationDays            int
+}
+
+// IdentityProviderConfig configures an external OAuth2/OIDC provider users
+// can sign in with. Endpoints left empty are discovered from the issuer.
+type IdentityProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	JWKSURL      string
+	RedirectURL  string
+	Scopes       []string
+	// TrustEmail treats emails as verified when the provider does not send
+	// an email_verified claim
+	TrustEmail bool
 }
 
 var AppConfig *Config
 
+// DefaultPasswordBlocklist is the built-in common password list, used when
+// PASSWORD_BLOCKLIST_FILE cannot be found
+//
+//go:embed password-blocklist.txt
+var DefaultPasswordBlocklist string
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() {
 	// Load .env file
@@ -35,33 +111,125 @@ func LoadConfig() {
 		log.Println("Warning: .env file not found, using system environment variables")
 	}
 
-	jwtExpHours, err := strconv.Atoi(getEnv("JWT_EXPIRATION_HOURS", "24"))
-	if err != nil {
-		jwtExpHours = 24
+	AppConfig = &Config{
+		Port:                         getEnv("PORT", "8080"),
+		DBHost:                       getEnv("DB_HOST", "localhost"),
+		DBPort:                       getEnv("DB_PORT", "3306"),
+		DBUser:                       getEnv("DB_USER", "root"),
+		DBPassword:                   getEnv("DB_PASSWORD", ""),
+		DBName:                       getEnv("DB_NAME", "auth_api_db"),
+		JWTSecret:                    getEnv("JWT_SECRET", "your-secret-key"),
+		JWTAlgorithm:                 getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKeyID:                     getEnv("JWT_KEY_ID", "default"),
+		JWTPrivateKeyFile:            getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTPublicKeys:                getEnv("JWT_PUBLIC_KEYS", ""),
+		JWTAllowEphemeralKey:         getEnvBool("JWT_ALLOW_EPHEMERAL_KEY", false),
+		AccessTokenMinutes:           getEnvInt("JWT_ACCESS_TOKEN_MINUTES", 15),
+		RefreshTokenDays:             getEnvInt("REFRESH_TOKEN_DAYS", 30),
+		TokenPurgeMinutes:            getEnvInt("TOKEN_PURGE_INTERVAL_MINUTES", 60),
+		SMTPHost:                     getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:                     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:                 getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:                 getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:                     getEnv("SMTP_FROM", "noreply@yourapp.com"),
+		FrontendURL:                  getEnv("FRONTEND_URL", "http://localhost:3000"),
+		GinMode:                      getEnv("GIN_MODE", "debug"),
+		TrustedProxies:               getEnvList("TRUSTED_PROXIES"),
+		AdminEmails:                  getEnvList("ADMIN_EMAILS"),
+		AppName:                      getEnv("APP_NAME", "Golang Auth API"),
+		MFAChallengeMinutes:          getEnvInt("MFA_CHALLENGE_MINUTES", 5),
+		LoginMaxAttempts:             getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts:           getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginLockoutMinutes:          getEnvInt("LOGIN_LOCKOUT_MINUTES", 15),
+		RateLimitEnabled:             getEnvBool("RATE_LIMIT_ENABLED", true),
+		PasswordResetTokenMinutes:    getEnvInt("PASSWORD_RESET_TOKEN_MINUTES", 60),
+		EmailVerificationTokenHours:  getEnvInt("EMAIL_VERIFICATION_TOKEN_HOURS", 48),
+		PasswordHashAlgorithm:        getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2MemoryKiB:              getEnvInt("ARGON2_MEMORY_KIB", 65536),
+		Argon2Iterations:             getEnvInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism:            getEnvInt("ARGON2_PARALLELISM", 2),
+		BcryptCost:                   getEnvInt("BCRYPT_COST", 12),
+		PasswordMinLength:            getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:            getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordRequireUppercase:     getEnvBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		PasswordRequireLowercase:     getEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		PasswordRequireDigit:         getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		PasswordRequireSymbol:        getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordDisallowPersonalInfo: getEnvBool("PASSWORD_DISALLOW_PERSONAL_INFO", true),
+		PasswordMinEntropyBits:       getEnvInt("PASSWORD_MIN_ENTROPY_BITS", 40),
+		PasswordBlocklistFile:        getEnv("PASSWORD_BLOCKLIST_FILE", "config/password-blocklist.txt"),
+		PasswordHistorySize:          getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+		MagicLinkMinutes:             getEnvInt("MAGIC_LINK_MINUTES", 15),
+		EmailVerificationMode:        getEnv("EMAIL_VERIFICATION_MODE", "link"),
+		PasswordResetMode:            getEnv("PASSWORD_RESET_MODE", "link"),
+		PasswordlessLoginMode:        getEnv("PASSWORDLESS_LOGIN_MODE", "link"),
+		EmailCodeMinutes:             getEnvInt("EMAIL_CODE_MINUTES", 10),
+		EmailCodeMaxAttempts:         getEnvInt("EMAIL_CODE_MAX_ATTEMPTS", 5),
+		WebAuthnRPID:                 getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnOrigins:              getEnvList("WEBAUTHN_ORIGINS"),
+		WebAuthnChallengeMinutes:     getEnvInt("WEBAUTHN_CHALLENGE_MINUTES", 5),
+		OAuthStateMinutes:            getEnvInt("OAUTH_STATE_MINUTES", 10),
+		OAuthServerScopes:            getEnvList("AUTH_SERVER_SCOPES"),
+		OAuthCodeMinutes:             getEnvInt("AUTH_SERVER_CODE_MINUTES", 1),
+		OAuthDeviceCodeMinutes:       getEnvInt("AUTH_SERVER_DEVICE_CODE_MINUTES", 10),
+		OAuthDevicePollSeconds:       getEnvInt("AUTH_SERVER_DEVICE_POLL_SECONDS", 5),
+		OrgInvitationDays:            getEnvInt("ORG_INVITATION_DAYS", 7),
 	}
 
-	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
-	if err != nil {
-		smtpPort = 587
+	// JWT_EXPIRATION_HOURS was the lifetime of the only token before refresh
+	// tokens existed. Keep honouring it so older .env files do not silently
+	// change how long tokens last.
+	if hours := getEnvInt("JWT_EXPIRATION_HOURS", 0); hours > 0 {
+		log.Println("Warning: JWT_EXPIRATION_HOURS is deprecated, use JWT_ACCESS_TOKEN_MINUTES and REFRESH_TOKEN_DAYS instead")
+		if os.Getenv("JWT_ACCESS_TOKEN_MINUTES") == "" {
+			AppConfig.AccessTokenMinutes = hours * 60
+		}
+		if os.Getenv("REFRESH_TOKEN_DAYS") == "" {
+			AppConfig.RefreshTokenDays = max(AppConfig.RefreshTokenDays, (hours+23)/24)
+		}
 	}
 
-	AppConfig = &Config{
-		Port:               getEnv("PORT", "8080"),
-		DBHost:             getEnv("DB_HOST", "localhost"),
-		DBPort:             getEnv("DB_PORT", "3306"),
-		DBUser:             getEnv("DB_USER", "root"),
-		DBPassword:         getEnv("DB_PASSWORD", ""),
-		DBName:             getEnv("DB_NAME", "auth_api_db"),
-		JWTSecret:          getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpirationHours: jwtExpHours,
-		SMTPHost:           getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:           smtpPort,
-		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
-		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
-		SMTPFrom:           getEnv("SMTP_FROM", "noreply@yourapp.com"),
-		FrontendURL:        getEnv("FRONTEND_URL", "http://localhost:3000"),
-		GinMode:            getEnv("GIN_MODE", "debug"),
+	AppConfig.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", AppConfig.AppName)
+	if len(AppConfig.WebAuthnOrigins) == 0 {
+		AppConfig.WebAuthnOrigins = []string{AppConfig.FrontendURL}
+	}
+
+	AppConfig.OAuthIssuer = strings.TrimSuffix(getEnv("AUTH_SERVER_ISSUER", "http://localhost:"+AppConfig.Port), "/")
+	if len(AppConfig.OAuthServerScopes) == 0 {
+		AppConfig.OAuthServerScopes = []string{"openid", "profile", "email", "offline_access"}
+	}
+
+	AppConfig.IdentityProviders = loadIdentityProviders(AppConfig.FrontendURL)
+}
+
+// loadIdentityProviders reads the providers named in OAUTH_PROVIDERS, each
+// configured with OAUTH_<NAME>_* variables
+func loadIdentityProviders(frontendURL string) []IdentityProviderConfig {
+	var providers []IdentityProviderConfig
+	for _, name := range getEnvList("OAUTH_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		scopes := getEnvList(prefix + "SCOPES")
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers = append(providers, IdentityProviderConfig{
+			Name:         name,
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			AuthURL:      getEnv(prefix+"AUTH_URL", ""),
+			TokenURL:     getEnv(prefix+"TOKEN_URL", ""),
+			UserInfoURL:  getEnv(prefix+"USERINFO_URL", ""),
+			JWKSURL:      getEnv(prefix+"JWKS_URL", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", frontendURL+"/oauth/"+name+"/callback"),
+			Scopes:       scopes,
+			TrustEmail:   getEnvBool(prefix+"TRUST_EMAIL", false),
+		})
 	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -71,3 +239,29 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
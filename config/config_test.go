@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestDeprecatedJWTExpirationHours(t *testing.T) {
+	tests := []struct {
+		name                 string
+		env                  map[string]string
+		wantAccessMinutes    int
+		wantRefreshTokenDays int
+	}{
+		{"unset", map[string]string{}, 15, 30},
+		{"falls back", map[string]string{"JWT_EXPIRATION_HOURS": "24"}, 1440, 30},
+		{"outlives refresh default", map[string]string{"JWT_EXPIRATION_HOURS": "1000"}, 60000, 42},
+		{"new settings win", map[string]string{"JWT_EXPIRATION_HOURS": "24", "JWT_ACCESS_TOKEN_MINUTES": "10", "REFRESH_TOKEN_DAYS": "7"}, 10, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"JWT_EXPIRATION_HOURS", "JWT_ACCESS_TOKEN_MINUTES", "REFRESH_TOKEN_DAYS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			LoadConfig()
+			if AppConfig.AccessTokenMinutes != tt.wantAccessMinutes || AppConfig.RefreshTokenDays != tt.wantRefreshTokenDays {
+				t.Fatalf("got %d access minutes and %d refresh days, want %d and %d",
+					AppConfig.AccessTokenMinutes, AppConfig.RefreshTokenDays, tt.wantAccessMinutes, tt.wantRefreshTokenDays)
+			}
+		})
+	}
+}
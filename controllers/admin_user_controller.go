@@ -0,0 +1,440 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+type AdminUserController struct {
+	tokenService          *services.TokenService
+	roleService           *services.RoleService
+	throttleService       *services.LoginThrottleService
+	emailChallengeService *services.EmailChallengeService
+	passwordPolicy        *services.PasswordPolicyService
+	passwordHistory       *services.PasswordHistoryService
+}
+
+// NewAdminUserController creates a new admin user controller
+func NewAdminUserController() *AdminUserController {
+	return &AdminUserController{
+		tokenService:          services.NewTokenService(),
+		roleService:           services.NewRoleService(),
+		throttleService:       services.NewLoginThrottleService(),
+		emailChallengeService: services.NewEmailChallengeService(),
+		passwordPolicy:        services.NewPasswordPolicyService(),
+		passwordHistory:       services.NewPasswordHistoryService(),
+	}
+}
+
+// AdminCreateUserRequest represents admin create user request body
+type AdminCreateUserRequest struct {
+	Email           string   `json:"email" binding:"required,email"`
+	Password        string   `json:"password" binding:"required"`
+	Name            string   `json:"name" binding:"required"`
+	Roles           []string `json:"roles"`
+	IsEmailVerified bool     `json:"is_email_verified"`
+}
+
+// AdminUpdateUserRequest represents admin update user request body
+type AdminUpdateUserRequest struct {
+	Email *string  `json:"email" binding:"omitempty,email"`
+	Name  *string  `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// AdminUserResponse exposes soft-delete state in admin responses
+type AdminUserResponse struct {
+	models.User
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// ListUsers returns users filtered by search, role, status and verification
+func (ctrl *AdminUserController) ListUsers(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+	query := database.DB.Model(&models.User{})
+
+	switch c.Query("status") {
+	case "", "active":
+		query = query.Where("disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	case "deleted":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case "all":
+		query = query.Unscoped()
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid status filter", "invalid_status")
+		return
+	}
+
+	if search := c.Query("search"); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("email LIKE ? OR name LIKE ?", like, like)
+	}
+
+	if verified := c.Query("verified"); verified != "" {
+		isVerified, err := strconv.ParseBool(verified)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid verified filter", "invalid_verified")
+			return
+		}
+		query = query.Where("is_email_verified = ?", isVerified)
+	}
+
+	if role := c.Query("role"); role != "" {
+		query = query.Where("id IN (?)", database.DB.Table("user_roles").
+			Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name = ?", role))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve users", err.Error())
+		return
+	}
+	pagination.SetTotal(total)
+
+	var users []models.User
+	if err := query.Preload("Roles").
+		Order("id DESC").
+		Offset(pagination.Offset()).
+		Limit(pagination.PerPage).
+		Find(&users).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve users", err.Error())
+		return
+	}
+
+	response := make([]AdminUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, adminUserResponse(user))
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Users retrieved successfully", gin.H{
+		"users":      response,
+		"pagination": pagination,
+	})
+}
+
+// GetUser returns a single user, including soft-deleted users
+func (ctrl *AdminUserController) GetUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, true)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User retrieved successfully", adminUserResponse(*user))
+}
+
+// CreateUser creates a user on behalf of an admin
+func (ctrl *AdminUserController) CreateUser(c *gin.Context) {
+	var req AdminCreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.User{}).Where("email = ?", req.Email).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "Email already registered", "email_exists")
+		return
+	}
+
+	user := models.User{
+		Email:           req.Email,
+		Password:        req.Password,
+		Name:            req.Name,
+		IsEmailVerified: req.IsEmailVerified,
+	}
+
+	if err := ctrl.passwordPolicy.Validate(req.Password, &user); err != nil {
+		passwordPolicyErrorResponse(c, err)
+		return
+	}
+
+	roles := req.Roles
+	if len(roles) == 0 {
+		roles = []string{models.RoleUser}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return ctrl.roleService.SetRoles(tx, &user, roles)
+	})
+	if errors.Is(err, services.ErrUnknownRole) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unknown role", err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "User created successfully", adminUserResponse(user))
+}
+
+// UpdateUser updates a user's profile and roles
+func (ctrl *AdminUserController) UpdateUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	var req AdminUpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Email != nil && *req.Email != user.Email {
+		var count int64
+		database.DB.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", *req.Email, user.ID).Count(&count)
+		if count > 0 {
+			utils.ErrorResponse(c, http.StatusConflict, "Email already registered", "email_exists")
+			return
+		}
+		user.Email = *req.Email
+		user.IsEmailVerified = false
+	}
+
+	if req.Name != nil && *req.Name != "" {
+		user.Name = *req.Name
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Roles").Save(user).Error; err != nil {
+			return err
+		}
+		if req.Roles != nil {
+			return ctrl.roleService.SetRoles(tx, user, req.Roles)
+		}
+		return nil
+	})
+	if errors.Is(err, services.ErrUnknownRole) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unknown role", err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user", err.Error())
+		return
+	}
+
+	// Tokens carry the user's roles, so make the user sign in again
+	if req.Roles != nil {
+		if _, err := ctrl.tokenService.InvalidateUserTokens(user, ""); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions", err.Error())
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User updated successfully", adminUserResponse(*user))
+}
+
+// DisableUser disables an account and signs it out everywhere
+func (ctrl *AdminUserController) DisableUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	if user.ID == c.GetUint("user_id") {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot disable your own account", "cannot_disable_self")
+		return
+	}
+
+	now := time.Now()
+	user.DisabledAt = &now
+	if err := database.DB.Model(user).Update("disabled_at", now).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable user", err.Error())
+		return
+	}
+
+	if _, err := ctrl.tokenService.InvalidateUserTokens(user, ""); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User disabled successfully", adminUserResponse(*user))
+}
+
+// EnableUser re-enables a disabled account
+func (ctrl *AdminUserController) EnableUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	user.DisabledAt = nil
+	if err := database.DB.Model(user).Update("disabled_at", nil).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable user", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User enabled successfully", adminUserResponse(*user))
+}
+
+// UnlockUser lifts a temporary lockout caused by failed logins
+func (ctrl *AdminUserController) UnlockUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.throttleService.Unlock(user); err != nil {
+		if errors.Is(err, services.ErrAccountNotLocked) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "User is not locked", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to unlock user", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User unlocked successfully", adminUserResponse(*user))
+}
+
+// ForcePasswordReset signs the user out, blocks password login until the
+// password is reset, clears the password history, and emails a reset link
+func (ctrl *AdminUserController) ForcePasswordReset(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	var challenge *services.EmailChallenge
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		user.PasswordResetRequired = true
+		if err := tx.Model(user).Update("password_reset_required", true).Error; err != nil {
+			return err
+		}
+		if err := ctrl.passwordHistory.Clear(tx, user.ID); err != nil {
+			return err
+		}
+
+		var err error
+		challenge, err = ctrl.emailChallengeService.Issue(tx, user, models.TokenPurposePasswordReset)
+		return err
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to force password reset", err.Error())
+		return
+	}
+
+	if _, err := ctrl.tokenService.InvalidateUserTokens(user, ""); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions", err.Error())
+		return
+	}
+
+	ctrl.emailChallengeService.Send(challenge)
+
+	utils.SuccessResponse(c, http.StatusOK, "Password reset has been required for the user", adminUserResponse(*user))
+}
+
+// VerifyUserEmail marks a user's email as verified
+func (ctrl *AdminUserController) VerifyUserEmail(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	user.IsEmailVerified = true
+	if err := database.DB.Model(user).Update("is_email_verified", true).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Email verified successfully", adminUserResponse(*user))
+}
+
+// DeleteUser soft deletes a user and signs it out everywhere
+func (ctrl *AdminUserController) DeleteUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, false)
+	if !ok {
+		return
+	}
+
+	if user.ID == c.GetUint("user_id") {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot delete your own account", "cannot_delete_self")
+		return
+	}
+
+	if err := database.DB.Delete(user).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete user", err.Error())
+		return
+	}
+
+	if _, err := ctrl.tokenService.InvalidateUserTokens(user, ""); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke user sessions", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User deleted successfully", nil)
+}
+
+// RestoreUser restores a soft-deleted user
+func (ctrl *AdminUserController) RestoreUser(c *gin.Context) {
+	user, ok := ctrl.findUser(c, true)
+	if !ok {
+		return
+	}
+
+	if !user.DeletedAt.Valid {
+		utils.ErrorResponse(c, http.StatusBadRequest, "User is not deleted", "not_deleted")
+		return
+	}
+
+	if err := database.DB.Unscoped().Model(user).Update("deleted_at", nil).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore user", err.Error())
+		return
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+
+	utils.SuccessResponse(c, http.StatusOK, "User restored successfully", adminUserResponse(*user))
+}
+
+// findUser loads the user identified by the :id path parameter, writing an
+// error response when it cannot be found
+func (ctrl *AdminUserController) findUser(c *gin.Context, includeDeleted bool) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", "invalid_id")
+		return nil, false
+	}
+
+	query := database.DB.Preload("Roles")
+	if includeDeleted {
+		query = query.Unscoped()
+	}
+
+	var user models.User
+	if err := query.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "User not found", "user_not_found")
+			return nil, false
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve user", err.Error())
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// adminUserResponse converts a user into its admin representation
+func adminUserResponse(user models.User) AdminUserResponse {
+	response := AdminUserResponse{User: user}
+	if user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	return response
+}
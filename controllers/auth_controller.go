@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,23 +14,46 @@ import (
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
 )
 
 type AuthController struct {
-	emailService *services.EmailService
+	tokenService          *services.TokenService
+	sessionService        *services.SessionService
+	roleService           *services.RoleService
+	mfaService            *services.MFAService
+	throttleService       *services.LoginThrottleService
+	oneTimeTokenService   *services.OneTimeTokenService
+	emailChallengeService *services.EmailChallengeService
+	magicLinkService      *services.MagicLinkService
+	passwordPolicy        *services.PasswordPolicyService
+	passwordHistory       *services.PasswordHistoryService
+	webauthnService       *services.WebAuthnService
+	socialLoginService    *services.SocialLoginService
 }
 
 // NewAuthController creates a new auth controller
 func NewAuthController() *AuthController {
 	return &AuthController{
-		emailService: services.NewEmailService(),
+		tokenService:          services.NewTokenService(),
+		sessionService:        services.NewSessionService(),
+		roleService:           services.NewRoleService(),
+		mfaService:            services.NewMFAService(),
+		throttleService:       services.NewLoginThrottleService(),
+		oneTimeTokenService:   services.NewOneTimeTokenService(),
+		emailChallengeService: services.NewEmailChallengeService(),
+		magicLinkService:      services.NewMagicLinkService(),
+		passwordPolicy:        services.NewPasswordPolicyService(),
+		passwordHistory:       services.NewPasswordHistoryService(),
+		webauthnService:       services.NewWebAuthnService(),
+		socialLoginService:    services.NewSocialLoginService(),
 	}
 }
 
 // RegisterRequest represents registration request body
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Name     string `json:"name" binding:"required"`
 }
 
@@ -40,16 +68,75 @@ type ForgotPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
 
-// ResetPasswordRequest represents reset password request body
+// ResetPasswordRequest represents reset password request body. Either the
+// token from the reset link or the email and emailed code is required.
 type ResetPasswordRequest struct {
-	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	Token       string `json:"token" binding:"required_without=Code"`
+	Email       string `json:"email" binding:"required_with=Code,omitempty,email"`
+	Code        string `json:"code" binding:"required_without=Token"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// VerifyEmailCodeRequest represents email verification by code request body
+type VerifyEmailCodeRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// VerifyMFARequest represents the second step of a two-step login. One of
+// code, recovery_code or passkey is required.
+type VerifyMFARequest struct {
+	MFAToken     string                     `json:"mfa_token" binding:"required"`
+	Code         string                     `json:"code"`
+	RecoveryCode string                     `json:"recovery_code"`
+	Passkey      *services.PasskeyAssertion `json:"passkey"`
+}
+
+// MFAPasskeyOptionsRequest represents a request for passkey options to
+// answer an MFA challenge
+type MFAPasskeyOptionsRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// FinishPasskeyLoginRequest represents the result of a passwordless passkey login
+type FinishPasskeyLoginRequest struct {
+	SessionToken string                    `json:"session_token" binding:"required"`
+	Credential   utils.PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// MagicLinkRequest represents passwordless sign-in link request body
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ConfirmMagicLinkRequest represents magic link confirmation request body.
+// Either the token from the link or the email and emailed code is required.
+type ConfirmMagicLinkRequest struct {
+	Token string `json:"token" binding:"required_without=Code"`
+	Email string `json:"email" binding:"required_with=Code,omitempty,email"`
+	Code  string `json:"code" binding:"required_without=Token"`
+}
+
+// OAuthCallbackRequest represents the parameters an external provider
+// redirected back with, together with the session token from authorize
+type OAuthCallbackRequest struct {
+	SessionToken string `json:"session_token" binding:"required"`
+	State        string `json:"state" binding:"required"`
+	Code         string `json:"code" binding:"required"`
+}
+
+// RefreshTokenRequest represents refresh token request body
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // ChangePasswordRequest represents change password request body
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
+	// KeepCurrentSession keeps the session making the request signed in and
+	// returns a new token pair for it
+	KeepCurrentSession bool `json:"keep_current_session"`
 }
 
 // Register handles user registration
@@ -60,53 +147,56 @@ func (ctrl *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
+	// Check if user already exists, including soft-deleted accounts
 	var existingUser models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if err := database.DB.Unscoped().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
 		utils.ErrorResponse(c, http.StatusConflict, "Email already registered", "email_exists")
 		return
 	}
 
-	// Generate verification token
-	verificationToken, err := utils.GenerateRandomToken(32)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate verification token", err.Error())
-		return
-	}
-
 	// Create new user
 	user := models.User{
-		Email:             req.Email,
-		Password:          req.Password,
-		Name:              req.Name,
-		VerificationToken: verificationToken,
-		IsEmailVerified:   false,
+		Email:           req.Email,
+		Password:        req.Password,
+		Name:            req.Name,
+		IsEmailVerified: false,
+	}
+
+	if err := ctrl.passwordPolicy.Validate(req.Password, &user); err != nil {
+		passwordPolicyErrorResponse(c, err)
+		return
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	var verification *services.EmailChallenge
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := ctrl.roleService.AssignDefaultRoles(tx, &user); err != nil {
+			return err
+		}
+
+		// Generate verification link or code
+		var err error
+		verification, err = ctrl.emailChallengeService.Issue(tx, &user, models.TokenPurposeEmailVerification)
+		return err
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create user", err.Error())
 		return
 	}
 
 	// Send verification email
-	go func() {
-		if err := ctrl.emailService.SendVerificationEmail(user.Email, verificationToken); err != nil {
-			// Log error but don't fail the request
-			println("Failed to send verification email:", err.Error())
-		}
-	}()
+	ctrl.emailChallengeService.Send(verification)
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email)
+	// Generate access and refresh tokens
+	tokens, err := ctrl.startSession(c, &user)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token", err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully", gin.H{
-		"user":  user,
-		"token": token,
-	})
+	utils.SuccessResponse(c, http.StatusCreated, "User registered successfully", tokenResponse(&user, tokens))
 }
 
 // Login handles user login
@@ -117,32 +207,376 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email
-	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid email or password", "invalid_credentials")
+	// Find user by email; unknown emails are still throttled by IP
+	var user *models.User
+	var found models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&found).Error; err == nil {
+		user = &found
+	}
+
+	ip := c.ClientIP()
+	if err := ctrl.throttleService.Check(user, ip); err != nil {
+		throttleErrorResponse(c, err)
 		return
 	}
 
 	// Check password
-	if !user.CheckPassword(req.Password) {
+	var passwordOK, needsRehash bool
+	if user != nil {
+		passwordOK, needsRehash = user.VerifyPassword(req.Password)
+	}
+	if !passwordOK {
+		locked, err := ctrl.throttleService.RecordFailure(user, ip)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+			return
+		}
+		if locked {
+			throttleErrorResponse(c, &services.ThrottleError{Err: services.ErrAccountLocked, RetryAfter: time.Until(*user.LockedUntil)})
+			return
+		}
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid email or password", "invalid_credentials")
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Email)
+	// Upgrade hashes made with an older algorithm or weaker parameters
+	if needsRehash {
+		if err := rehashPassword(user, req.Password); err != nil {
+			log.Printf("Failed to rehash password for user %d: %v", user.ID, err)
+		}
+	}
+
+	if user.IsDisabled() {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", "account_disabled")
+		return
+	}
+
+	if user.PasswordResetRequired {
+		utils.ErrorResponse(c, http.StatusForbidden, "Password reset is required, please check your email", "password_reset_required")
+		return
+	}
+
+	ctrl.completeLogin(c, user)
+}
+
+// completeLogin asks for the second factor if the user has one, otherwise
+// starts a session and responds with the token pair
+func (ctrl *AuthController) completeLogin(c *gin.Context, user *models.User) {
+	// Require the second factor before issuing tokens
+	if user.TOTPEnabled {
+		mfaToken, err := ctrl.mfaService.IssueChallenge(user)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate MFA challenge", err.Error())
+			return
+		}
+
+		methods := []string{"totp", "recovery_code"}
+		passkeys, err := ctrl.webauthnService.Count(user.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate MFA challenge", err.Error())
+			return
+		}
+		if passkeys > 0 {
+			methods = append(methods, "passkey")
+		}
+
+		utils.SuccessResponse(c, http.StatusOK, "Multi-factor authentication required", gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"mfa_methods":  methods,
+		})
+		return
+	}
+
+	// Failed attempts are only forgiven once every factor has been passed
+	if err := ctrl.throttleService.RecordSuccess(user); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+		return
+	}
+
+	// Generate access and refresh tokens
+	tokens, err := ctrl.startSession(c, user)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token", err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Login successful", gin.H{
-		"user":  user,
-		"token": token,
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", tokenResponse(user, tokens))
+}
+
+// RequestMagicLink emails a single-use passwordless sign-in link
+func (ctrl *AuthController) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	delivery := gin.H{"delivery": services.DeliveryMode(models.TokenPurposeMagicLink)}
+
+	// Don't reveal whether the email exists
+	var user models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil || user.IsDisabled() {
+		utils.SuccessResponse(c, http.StatusOK, "If the email exists, sign-in instructions have been sent", delivery)
+		return
+	}
+
+	challenge, err := ctrl.emailChallengeService.Issue(database.DB, &user, models.TokenPurposeMagicLink)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate sign-in link", err.Error())
+		return
+	}
+
+	ctrl.emailChallengeService.Send(challenge)
+
+	utils.SuccessResponse(c, http.StatusOK, "If the email exists, sign-in instructions have been sent", delivery)
+}
+
+// CheckMagicLink reports whether a sign-in link is still valid without using
+// it, so mail scanners that prefetch links cannot sign the user in
+func (ctrl *AuthController) CheckMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Sign-in token is required", "missing_token")
+		return
+	}
+
+	if _, err := ctrl.oneTimeTokenService.Lookup(database.DB, token, models.TokenPurposeMagicLink); err != nil {
+		oneTimeTokenErrorResponse(c, err, "Invalid sign-in link", "Sign-in link has expired", "Failed to check sign-in link")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Sign-in link is valid, confirm to sign in", gin.H{
+		"valid": true,
+	})
+}
+
+// ConfirmMagicLink exchanges a sign-in link token or code for the same
+// response as Login
+func (ctrl *AuthController) ConfirmMagicLink(c *gin.Context) {
+	var req ConfirmMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, err := ctrl.magicLinkService.Confirm(req.Token, req.Email, req.Code)
+	if err != nil {
+		oneTimeTokenErrorResponse(c, err, "Invalid sign-in link", "Sign-in link has expired", "Failed to sign in")
+		return
+	}
+
+	if user.IsDisabled() {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", "account_disabled")
+		return
+	}
+
+	if user.PasswordResetRequired {
+		utils.ErrorResponse(c, http.StatusForbidden, "Password reset is required, please check your email", "password_reset_required")
+		return
+	}
+
+	ctrl.completeLogin(c, user)
+}
+
+// BeginPasskeyLogin starts a passwordless login with a discoverable passkey
+func (ctrl *AuthController) BeginPasskeyLogin(c *gin.Context) {
+	options, sessionToken, err := ctrl.webauthnService.BeginLogin(nil, services.UserVerificationRequired)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start passkey login", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Sign in with your passkey", gin.H{
+		"options":       options,
+		"session_token": sessionToken,
 	})
 }
 
+// FinishPasskeyLogin verifies a passkey assertion and signs the user in. The
+// passkey verified the user itself, so no second factor is asked for.
+func (ctrl *AuthController) FinishPasskeyLogin(c *gin.Context) {
+	var req FinishPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, err := ctrl.webauthnService.FinishLogin(req.SessionToken, &req.Credential)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyNotFound) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Passkey is not registered", err.Error())
+			return
+		}
+		passkeyErrorResponse(c, err, "Failed to sign in with passkey")
+		return
+	}
+
+	if user.IsDisabled() {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", "account_disabled")
+		return
+	}
+
+	if user.PasswordResetRequired {
+		utils.ErrorResponse(c, http.StatusForbidden, "Password reset is required, please check your email", "password_reset_required")
+		return
+	}
+
+	tokens, err := ctrl.startSession(c, user)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", tokenResponse(user, tokens))
+}
+
+// ListIdentityProviders returns the external providers users can sign in with
+func (ctrl *AuthController) ListIdentityProviders(c *gin.Context) {
+	names := services.IdentityProviderNames()
+	sort.Strings(names)
+
+	utils.SuccessResponse(c, http.StatusOK, "Identity providers retrieved successfully", gin.H{
+		"providers": names,
+	})
+}
+
+// BeginOAuthLogin returns the provider URL to send the user to for signing in
+func (ctrl *AuthController) BeginOAuthLogin(c *gin.Context) {
+	authURL, sessionToken, err := ctrl.socialLoginService.Begin(c.Request.Context(), c.Param("provider"), 0)
+	if err != nil {
+		socialLoginErrorResponse(c, err, "Failed to start sign-in")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Redirect the user to the authorization URL", gin.H{
+		"authorization_url": authURL,
+		"session_token":     sessionToken,
+	})
+}
+
+// OAuthCallback completes a sign-in with an external provider with the same
+// response as Login
+func (ctrl *AuthController) OAuthCallback(c *gin.Context) {
+	var req OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, err := ctrl.socialLoginService.Login(c.Request.Context(), c.Param("provider"), req.SessionToken, req.State, req.Code)
+	if err != nil {
+		socialLoginErrorResponse(c, err, "Failed to sign in")
+		return
+	}
+
+	if user.IsDisabled() {
+		utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", "account_disabled")
+		return
+	}
+
+	if user.PasswordResetRequired {
+		utils.ErrorResponse(c, http.StatusForbidden, "Password reset is required, please check your email", "password_reset_required")
+		return
+	}
+
+	ctrl.completeLogin(c, user)
+}
+
+// MFAPasskeyOptions returns passkey options for answering an MFA challenge
+func (ctrl *AuthController) MFAPasskeyOptions(c *gin.Context) {
+	var req MFAPasskeyOptionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	options, sessionToken, err := ctrl.mfaService.BeginPasskeyChallenge(req.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMFAToken):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired MFA token, please login again", err.Error())
+		case errors.Is(err, services.ErrPasskeyNotFound):
+			utils.ErrorResponse(c, http.StatusBadRequest, "No passkeys are registered", err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start passkey verification", err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Verify with your passkey", gin.H{
+		"options":       options,
+		"session_token": sessionToken,
+	})
+}
+
+// VerifyMFA completes a two-step login with a TOTP code, recovery code or passkey
+func (ctrl *AuthController) VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, err := ctrl.mfaService.VerifyChallenge(req.MFAToken, services.MFAFactor{
+		Code:         req.Code,
+		RecoveryCode: req.RecoveryCode,
+		Passkey:      req.Passkey,
+	}, c.ClientIP())
+	if err != nil {
+		var throttleErr *services.ThrottleError
+		switch {
+		case errors.As(err, &throttleErr):
+			throttleErrorResponse(c, err)
+		case errors.Is(err, services.ErrInvalidMFAToken):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired MFA token, please login again", err.Error())
+		case errors.Is(err, services.ErrMFAAttemptsExceeded):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Too many invalid codes, please login again", err.Error())
+		case errors.Is(err, services.ErrInvalidMFACode):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid verification code", err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify MFA code", err.Error())
+		}
+		return
+	}
+
+	tokens, err := ctrl.startSession(c, user)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Login successful", tokenResponse(user, tokens))
+}
+
+// RefreshToken rotates a refresh token and issues a new token pair
+func (ctrl *AuthController) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	tokens, user, err := ctrl.tokenService.Refresh(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Refresh token has already been used, please login again", err.Error())
+		case errors.Is(err, services.ErrAccountDisabled):
+			utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", err.Error())
+		case errors.Is(err, services.ErrRefreshTokenExpired):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Refresh token has expired", err.Error())
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid refresh token", err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to refresh token", err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Token refreshed successfully", tokenResponse(user, tokens))
+}
+
 // GetProfile returns the authenticated user's profile
 func (ctrl *AuthController) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -152,7 +586,7 @@ func (ctrl *AuthController) GetProfile(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	if err := database.DB.Preload("Roles").First(&user, userID).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "User not found", err.Error())
 		return
 	}
@@ -204,39 +638,27 @@ func (ctrl *AuthController) ForgotPassword(c *gin.Context) {
 		return
 	}
 
+	delivery := gin.H{"delivery": services.DeliveryMode(models.TokenPurposePasswordReset)}
+
 	// Find user by email
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
 		// Don't reveal if user exists or not
-		utils.SuccessResponse(c, http.StatusOK, "If the email exists, a reset link has been sent", nil)
+		utils.SuccessResponse(c, http.StatusOK, "If the email exists, password reset instructions have been sent", delivery)
 		return
 	}
 
-	// Generate reset token
-	resetToken, err := utils.GenerateRandomToken(32)
+	// Generate reset link or code
+	challenge, err := ctrl.emailChallengeService.Issue(database.DB, &user, models.TokenPurposePasswordReset)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate reset token", err.Error())
 		return
 	}
 
-	// Set token and expiry (1 hour)
-	expiryTime := time.Now().Add(1 * time.Hour)
-	user.ResetToken = resetToken
-	user.ResetTokenExpiry = &expiryTime
-
-	if err := database.DB.Save(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save reset token", err.Error())
-		return
-	}
-
 	// Send reset email
-	go func() {
-		if err := ctrl.emailService.SendPasswordResetEmail(user.Email, resetToken); err != nil {
-			println("Failed to send reset email:", err.Error())
-		}
-	}()
+	ctrl.emailChallengeService.Send(challenge)
 
-	utils.SuccessResponse(c, http.StatusOK, "If the email exists, a reset link has been sent", nil)
+	utils.SuccessResponse(c, http.StatusOK, "If the email exists, password reset instructions have been sent", delivery)
 }
 
 // ResetPassword handles password reset
@@ -247,33 +669,53 @@ func (ctrl *AuthController) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Find user by reset token
+	// Consume the reset token and update the password together; a rejected
+	// password rolls back so the token can be used again
 	var user models.User
-	if err := database.DB.Where("reset_token = ?", req.Token).First(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired reset token", "invalid_token")
-		return
-	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := ctrl.emailChallengeService.Consume(tx, models.TokenPurposePasswordReset, req.Token, req.Email, req.Code)
+		if err != nil {
+			return err
+		}
 
-	// Check if token is expired
-	if user.ResetTokenExpiry == nil || time.Now().After(*user.ResetTokenExpiry) {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Reset token has expired", "token_expired")
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+
+		if err := ctrl.passwordPolicy.Validate(req.NewPassword, &user); err != nil {
+			return err
+		}
+		if err := ctrl.passwordHistory.CheckReuse(tx, &user, req.NewPassword); err != nil {
+			return err
+		}
+
+		hashedPassword, err := models.HashPassword(req.NewPassword)
+		if err != nil {
+			return err
+		}
+
+		if err := ctrl.passwordHistory.Record(tx, user.ID, user.Password); err != nil {
+			return err
+		}
+
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"password":                hashedPassword,
+			"password_reset_required": false,
+		}).Error
+	})
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		passwordPolicyErrorResponse(c, err)
 		return
 	}
-
-	// Hash new password
-	hashedPassword, err := models.HashPassword(req.NewPassword)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to hash password", err.Error())
+		oneTimeTokenErrorResponse(c, err, "Invalid or expired reset token", "Reset token has expired", "Failed to reset password")
 		return
 	}
 
-	// Update password and clear reset token
-	user.Password = hashedPassword
-	user.ResetToken = ""
-	user.ResetTokenExpiry = nil
-
-	if err := database.DB.Save(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password", err.Error())
+	// Sign out everywhere in case the old password was compromised
+	if _, err := ctrl.tokenService.InvalidateUserTokens(&user, ""); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke existing sessions", err.Error())
 		return
 	}
 
@@ -300,12 +742,41 @@ func (ctrl *AuthController) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Check old password
+	// Check old password, counting wrong guesses like failed logins
+	ip := c.ClientIP()
+	if err := ctrl.throttleService.Check(&user, ip); err != nil {
+		throttleErrorResponse(c, err)
+		return
+	}
+
 	if !user.CheckPassword(req.OldPassword) {
+		locked, err := ctrl.throttleService.RecordFailure(&user, ip)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+			return
+		}
+		if locked {
+			throttleErrorResponse(c, &services.ThrottleError{Err: services.ErrAccountLocked, RetryAfter: time.Until(*user.LockedUntil)})
+			return
+		}
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Current password is incorrect", "invalid_password")
 		return
 	}
 
+	if err := ctrl.throttleService.RecordSuccess(&user); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+		return
+	}
+
+	if err := ctrl.passwordPolicy.Validate(req.NewPassword, &user); err != nil {
+		passwordPolicyErrorResponse(c, err)
+		return
+	}
+	if err := ctrl.passwordHistory.CheckReuse(database.DB, &user, req.NewPassword); err != nil {
+		passwordPolicyErrorResponse(c, err)
+		return
+	}
+
 	// Hash new password
 	hashedPassword, err := models.HashPassword(req.NewPassword)
 	if err != nil {
@@ -313,17 +784,39 @@ func (ctrl *AuthController) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Update password
-	user.Password = hashedPassword
-	if err := database.DB.Save(&user).Error; err != nil {
+	// Remember the old password and update it
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ctrl.passwordHistory.Record(tx, user.ID, user.Password); err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("password", hashedPassword).Error
+	})
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change password", err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Password changed successfully", nil)
+	// Invalidate every existing token, optionally keeping this session alive
+	keepSessionID := ""
+	if req.KeepCurrentSession {
+		keepSessionID = c.GetString("session_id")
+	}
+
+	tokens, err := ctrl.tokenService.InvalidateUserTokens(&user, keepSessionID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke existing sessions", err.Error())
+		return
+	}
+
+	if tokens == nil {
+		utils.SuccessResponse(c, http.StatusOK, "Password changed successfully", nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Password changed successfully", tokenResponse(&user, tokens))
 }
 
-// VerifyEmail handles email verification
+// VerifyEmail handles email verification via the emailed link
 func (ctrl *AuthController) VerifyEmail(c *gin.Context) {
 	token := c.Query("token")
 	if token == "" {
@@ -331,29 +824,145 @@ func (ctrl *AuthController) VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	// Find user by verification token
-	var user models.User
-	if err := database.DB.Where("verification_token = ?", token).First(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid verification token", "invalid_token")
+	ctrl.verifyEmail(c, token, "", "")
+}
+
+// VerifyEmailCode handles email verification via an emailed code
+func (ctrl *AuthController) VerifyEmailCode(c *gin.Context) {
+	var req VerifyEmailCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
 		return
 	}
 
-	// Update user as verified
-	user.IsEmailVerified = true
-	user.VerificationToken = ""
+	ctrl.verifyEmail(c, "", req.Email, req.Code)
+}
+
+// verifyEmail consumes a verification link token or code and marks the
+// user as verified
+func (ctrl *AuthController) verifyEmail(c *gin.Context, token, email, code string) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		verification, err := ctrl.emailChallengeService.Consume(tx, models.TokenPurposeEmailVerification, token, email, code)
+		if err != nil {
+			return err
+		}
 
-	if err := database.DB.Save(&user).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email", err.Error())
+		var user models.User
+		if err := tx.First(&user, verification.UserID).Error; err != nil {
+			return err
+		}
+
+		user.IsEmailVerified = true
+		if err := tx.Model(&user).Update("is_email_verified", true).Error; err != nil {
+			return err
+		}
+		return ctrl.roleService.AssignConfiguredAdmin(tx, &user)
+	})
+	if err != nil {
+		oneTimeTokenErrorResponse(c, err, "Invalid verification token", "Verification token has expired", "Failed to verify email")
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
 }
 
-// Logout handles user logout (client-side token removal)
+// Logout revokes the current access token and ends its session
 func (ctrl *AuthController) Logout(c *gin.Context) {
-	// In a JWT-based system, logout is typically handled client-side
-	// by removing the token from storage.
-	// For additional security, you could implement token blacklisting here.
+	if err := endCurrentSession(c, ctrl.sessionService); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to logout", err.Error())
+		return
+	}
+
 	utils.SuccessResponse(c, http.StatusOK, "Logged out successfully", nil)
 }
+
+// endCurrentSession revokes the request's access token and signs out its session
+func endCurrentSession(c *gin.Context, sessionService *services.SessionService) error {
+	userID := c.GetUint("user_id")
+
+	if err := services.Revocations.Revoke(c.GetString("token_id"), userID, c.GetTime("token_expires_at")); err != nil {
+		return err
+	}
+
+	if err := sessionService.Revoke(userID, c.GetString("session_id")); err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// startSession records a new session for the request's device and issues tokens for it
+func (ctrl *AuthController) startSession(c *gin.Context, user *models.User) (*services.TokenPair, error) {
+	session, err := ctrl.sessionService.Create(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	return ctrl.tokenService.IssueTokenPair(user, session)
+}
+
+// throttleErrorResponse reports a throttled login with a Retry-After header
+func throttleErrorResponse(c *gin.Context, err error) {
+	var throttleErr *services.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check login attempts", err.Error())
+		return
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttleErr.RetryAfter.Seconds()))))
+
+	if errors.Is(err, services.ErrAccountLocked) {
+		utils.ErrorResponse(c, http.StatusLocked, "Account is temporarily locked due to too many failed login attempts", err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many login attempts, please try again later", err.Error())
+}
+
+// passwordPolicyErrorResponse reports which password policy rules failed
+func passwordPolicyErrorResponse(c *gin.Context, err error) {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate password", err.Error())
+		return
+	}
+
+	utils.ErrorResponseWithData(c, http.StatusBadRequest, "Password does not meet the password policy", err.Error(), gin.H{
+		"violations": policyErr.Violations,
+	})
+}
+
+// rehashPassword stores the password again using the configured hasher
+func rehashPassword(user *models.User, password string) error {
+	hashedPassword, err := models.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hashedPassword
+	return database.DB.Model(user).Update("password", hashedPassword).Error
+}
+
+// oneTimeTokenErrorResponse reports a failure to consume an emailed token
+func oneTimeTokenErrorResponse(c *gin.Context, err error, invalidMessage, expiredMessage, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOneTimeToken):
+		utils.ErrorResponse(c, http.StatusBadRequest, invalidMessage, err.Error())
+	case errors.Is(err, services.ErrOneTimeTokenExpired):
+		utils.ErrorResponse(c, http.StatusBadRequest, expiredMessage, err.Error())
+	case errors.Is(err, services.ErrCodeAttemptsExceeded):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Too many invalid codes, please request a new one", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
+
+// tokenResponse builds the response body returned after a successful authentication
+func tokenResponse(user *models.User, tokens *services.TokenPair) gin.H {
+	return gin.H{
+		"user":          user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+	}
+}
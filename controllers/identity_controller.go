@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type IdentityController struct {
+	socialLoginService *services.SocialLoginService
+}
+
+// NewIdentityController creates a new identity controller
+func NewIdentityController() *IdentityController {
+	return &IdentityController{
+		socialLoginService: services.NewSocialLoginService(),
+	}
+}
+
+// ListIdentities returns the external identities linked to the user
+func (ctrl *IdentityController) ListIdentities(c *gin.Context) {
+	identities, err := ctrl.socialLoginService.List(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve identities", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Identities retrieved successfully", identities)
+}
+
+// BeginLink returns the provider URL to send the user to for linking
+func (ctrl *IdentityController) BeginLink(c *gin.Context) {
+	authURL, sessionToken, err := ctrl.socialLoginService.Begin(c.Request.Context(), c.Param("provider"), c.GetUint("user_id"))
+	if err != nil {
+		socialLoginErrorResponse(c, err, "Failed to start linking")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Redirect the user to the authorization URL", gin.H{
+		"authorization_url": authURL,
+		"session_token":     sessionToken,
+	})
+}
+
+// LinkIdentity completes linking an external identity to the user
+func (ctrl *IdentityController) LinkIdentity(c *gin.Context) {
+	var req OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	identity, err := ctrl.socialLoginService.Link(c.Request.Context(), c.GetUint("user_id"), c.Param("provider"), req.SessionToken, req.State, req.Code)
+	if err != nil {
+		socialLoginErrorResponse(c, err, "Failed to link identity")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Identity linked successfully", identity)
+}
+
+// UnlinkIdentity removes an external identity from the user
+func (ctrl *IdentityController) UnlinkIdentity(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid identity ID", err.Error())
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.socialLoginService.Unlink(user, uint(id)); err != nil {
+		socialLoginErrorResponse(c, err, "Failed to unlink identity")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Identity unlinked successfully", nil)
+}
+
+// socialLoginErrorResponse maps social login errors to responses
+func socialLoginErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrIdentityProviderNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Identity provider not found", err.Error())
+	case errors.Is(err, services.ErrInvalidOAuthState):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired sign-in state, please try again", err.Error())
+	case errors.Is(err, services.ErrInvalidIDToken):
+		utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider returned an invalid ID token", services.ErrInvalidIDToken.Error())
+	case errors.Is(err, services.ErrIdentityProviderFailed):
+		utils.ErrorResponse(c, http.StatusBadGateway, "Identity provider request failed", services.ErrIdentityProviderFailed.Error())
+	case errors.Is(err, services.ErrExternalEmailNotVerified):
+		utils.ErrorResponse(c, http.StatusForbidden, "The provider did not return a verified email address", err.Error())
+	case errors.Is(err, services.ErrExternalEmailUnavailable):
+		utils.ErrorResponse(c, http.StatusConflict, "Email already registered", err.Error())
+	case errors.Is(err, services.ErrAccountDisabled):
+		utils.ErrorResponse(c, http.StatusForbidden, "Account has been disabled", err.Error())
+	case errors.Is(err, services.ErrIdentityAlreadyLinked):
+		utils.ErrorResponse(c, http.StatusConflict, "This identity is linked to another account", err.Error())
+	case errors.Is(err, services.ErrIdentityNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Identity not found", err.Error())
+	case errors.Is(err, services.ErrLastSignInMethod):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Set a password or add a passkey before unlinking your last identity", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type MFAController struct {
+	mfaService      *services.MFAService
+	webauthnService *services.WebAuthnService
+	throttleService *services.LoginThrottleService
+}
+
+// NewMFAController creates a new MFA controller
+func NewMFAController() *MFAController {
+	return &MFAController{
+		mfaService:      services.NewMFAService(),
+		webauthnService: services.NewWebAuthnService(),
+		throttleService: services.NewLoginThrottleService(),
+	}
+}
+
+// ConfirmTOTPRequest represents TOTP enrollment confirmation request body
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// PasswordConfirmationRequest represents a request re-confirming the user's password
+type PasswordConfirmationRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// GetMFAStatus returns the authenticated user's MFA configuration
+func (ctrl *MFAController) GetMFAStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	remaining, err := ctrl.mfaService.RecoveryCodesRemaining(user.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve MFA status", err.Error())
+		return
+	}
+
+	passkeys, err := ctrl.webauthnService.Count(user.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve MFA status", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "MFA status retrieved successfully", gin.H{
+		"totp_enabled":             user.TOTPEnabled,
+		"recovery_codes_remaining": remaining,
+		"passkeys":                 passkeys,
+	})
+}
+
+// SetupTOTP starts TOTP enrollment and returns the secret and otpauth URI
+func (ctrl *MFAController) SetupTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := ctrl.mfaService.BeginTOTPEnrollment(user)
+	if err != nil {
+		if errors.Is(err, services.ErrTOTPAlreadyEnabled) {
+			utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start TOTP setup", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scan the QR code with your authenticator app and confirm with a code", gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTOTP enables TOTP and returns the initial recovery codes
+func (ctrl *MFAController) ConfirmTOTP(c *gin.Context) {
+	var req ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := ctrl.mfaService.ConfirmTOTPEnrollment(user, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTOTPAlreadyEnabled):
+			utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled", err.Error())
+		case errors.Is(err, services.ErrTOTPNotInitiated):
+			utils.ErrorResponse(c, http.StatusBadRequest, "TOTP setup has not been started", err.Error())
+		case errors.Is(err, services.ErrInvalidMFACode):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid verification code", err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication", err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication enabled, store your recovery codes safely", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP turns off TOTP after re-confirming the password
+func (ctrl *MFAController) DisableTOTP(c *gin.Context) {
+	user, ok := ctrl.confirmPassword(c)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.mfaService.DisableTOTP(user); err != nil {
+		if errors.Is(err, services.ErrTOTPNotEnabled) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor authentication is not enabled", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after re-confirming the password
+func (ctrl *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := ctrl.confirmPassword(c)
+	if !ok {
+		return
+	}
+
+	codes, err := ctrl.mfaService.RegenerateRecoveryCodes(user)
+	if err != nil {
+		if errors.Is(err, services.ErrTOTPNotEnabled) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor authentication is not enabled", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to regenerate recovery codes", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Recovery codes regenerated, store them safely", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+// currentUser loads the authenticated user, writing an error response on failure
+func currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found", err.Error())
+		return nil, false
+	}
+	return &user, true
+}
+
+// confirmPassword binds a PasswordConfirmationRequest and checks it against
+// the authenticated user's password. Wrong passwords count towards the same
+// throttle and lockout as failed logins.
+func (ctrl *MFAController) confirmPassword(c *gin.Context) (*models.User, bool) {
+	var req PasswordConfirmationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return nil, false
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	ip := c.ClientIP()
+	if err := ctrl.throttleService.Check(user, ip); err != nil {
+		throttleErrorResponse(c, err)
+		return nil, false
+	}
+
+	if !user.CheckPassword(req.Password) {
+		locked, err := ctrl.throttleService.RecordFailure(user, ip)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+			return nil, false
+		}
+		if locked {
+			throttleErrorResponse(c, &services.ThrottleError{Err: services.ErrAccountLocked, RetryAfter: time.Until(*user.LockedUntil)})
+			return nil, false
+		}
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Password is incorrect", "invalid_password")
+		return nil, false
+	}
+
+	if err := ctrl.throttleService.RecordSuccess(user); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record login attempt", err.Error())
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type OAuthClientController struct {
+	clientService *services.OAuthClientService
+}
+
+// NewOAuthClientController creates a new OAuth client admin controller
+func NewOAuthClientController() *OAuthClientController {
+	return &OAuthClientController{
+		clientService: services.NewOAuthClientService(),
+	}
+}
+
+// OAuthClientRequest represents OAuth client registration request body
+type OAuthClientRequest struct {
+	Name                   string   `json:"name" binding:"required,max=100"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	GrantTypes             []string `json:"grant_types" binding:"required,min=1"`
+	Scopes                 []string `json:"scopes"`
+	Confidential           bool     `json:"confidential"`
+	SkipConsent            bool     `json:"skip_consent"`
+}
+
+// OAuthClientSecretResponse includes a client secret, shown only once
+type OAuthClientSecretResponse struct {
+	models.OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// ListClients returns the registered OAuth clients
+func (ctrl *OAuthClientController) ListClients(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+
+	clients, err := ctrl.clientService.List(pagination)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve OAuth clients", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth clients retrieved successfully", gin.H{
+		"clients":    clients,
+		"pagination": pagination,
+	})
+}
+
+// GetClient returns a single OAuth client
+func (ctrl *OAuthClientController) GetClient(c *gin.Context) {
+	client, err := ctrl.clientService.Get(c.Param("client_id"))
+	if err != nil {
+		oauthClientErrorResponse(c, err, "Failed to retrieve OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth client retrieved successfully", client)
+}
+
+// CreateClient registers a new OAuth client
+func (ctrl *OAuthClientController) CreateClient(c *gin.Context) {
+	var req OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	client, secret, err := ctrl.clientService.Create(req.input())
+	if err != nil {
+		oauthClientErrorResponse(c, err, "Failed to register OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "OAuth client registered successfully, store the secret now as it will not be shown again", OAuthClientSecretResponse{
+		OAuthClient:  *client,
+		ClientSecret: secret,
+	})
+}
+
+// UpdateClient replaces an OAuth client's registration metadata
+func (ctrl *OAuthClientController) UpdateClient(c *gin.Context) {
+	var req OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	client, err := ctrl.clientService.Update(c.Param("client_id"), req.input())
+	if err != nil {
+		oauthClientErrorResponse(c, err, "Failed to update OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth client updated successfully", client)
+}
+
+// RotateClientSecret issues a new secret to a confidential client
+func (ctrl *OAuthClientController) RotateClientSecret(c *gin.Context) {
+	client, secret, err := ctrl.clientService.RotateSecret(c.Param("client_id"))
+	if err != nil {
+		oauthClientErrorResponse(c, err, "Failed to rotate client secret")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Client secret rotated successfully, store it now as it will not be shown again", OAuthClientSecretResponse{
+		OAuthClient:  *client,
+		ClientSecret: secret,
+	})
+}
+
+// DeleteClient removes an OAuth client and every token issued to it
+func (ctrl *OAuthClientController) DeleteClient(c *gin.Context) {
+	if err := ctrl.clientService.Delete(c.Param("client_id")); err != nil {
+		oauthClientErrorResponse(c, err, "Failed to delete OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "OAuth client deleted successfully", nil)
+}
+
+// input converts the request into registration metadata
+func (req OAuthClientRequest) input() services.OAuthClientInput {
+	return services.OAuthClientInput{
+		Name:                   req.Name,
+		RedirectURIs:           req.RedirectURIs,
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		GrantTypes:             req.GrantTypes,
+		Scopes:                 req.Scopes,
+		Confidential:           req.Confidential,
+		SkipConsent:            req.SkipConsent,
+	}
+}
+
+// oauthClientErrorResponse maps OAuth client service errors to responses
+func oauthClientErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrOAuthClientNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "OAuth client not found", err.Error())
+	case errors.Is(err, services.ErrInvalidClientMetadata):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid grant types or scopes for this client", err.Error())
+	case errors.Is(err, services.ErrInvalidClientRedirect):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Redirect URIs must be absolute URLs without a fragment", err.Error())
+	case errors.Is(err, services.ErrPublicClientHasNoSecret):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Public clients have no secret", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
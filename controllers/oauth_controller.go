@@ -0,0 +1,477 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type OAuthController struct {
+	serverService  *services.OAuthServerService
+	clientService  *services.OAuthClientService
+	sessionService *services.SessionService
+}
+
+// NewOAuthController creates a new OAuth authorization server controller
+func NewOAuthController() *OAuthController {
+	return &OAuthController{
+		serverService:  services.NewOAuthServerService(),
+		clientService:  services.NewOAuthClientService(),
+		sessionService: services.NewSessionService(),
+	}
+}
+
+// ApproveAuthorizationRequest represents the user's answer to an
+// authorization request
+type ApproveAuthorizationRequest struct {
+	services.AuthorizationRequest
+	Approve bool `json:"approve"`
+}
+
+// DeviceDecisionRequest represents the user's answer to a device
+// authorization
+type DeviceDecisionRequest struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+// Authorize is the browser entry point of the authorization code flow. Valid
+// requests are handed to the frontend consent page with their parameters.
+func (ctrl *OAuthController) Authorize(c *gin.Context) {
+	var req services.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid authorization request", err.Error())
+		return
+	}
+
+	_, redirectURI, err := ctrl.serverService.ValidateAuthorization(&req)
+	if err != nil {
+		if redirectURI == "" {
+			authorizationErrorResponse(c, err)
+			return
+		}
+		c.Redirect(http.StatusFound, services.AuthorizationErrorRedirect(redirectURI, err, req.State))
+		return
+	}
+
+	c.Redirect(http.StatusFound, config.AppConfig.FrontendURL+"/oauth/consent?"+c.Request.URL.RawQuery)
+}
+
+// GetAuthorization describes an authorization request to the consent page
+func (ctrl *OAuthController) GetAuthorization(c *gin.Context) {
+	var req services.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid authorization request", err.Error())
+		return
+	}
+
+	client, redirectURI, err := ctrl.serverService.ValidateAuthorization(&req)
+	if err != nil {
+		authorizationErrorResponseWithRedirect(c, err, redirectURI, req.State)
+		return
+	}
+
+	consentRequired, err := ctrl.serverService.ConsentRequired(c.GetUint("user_id"), client, req.Scope)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check consent", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Authorization request is valid", gin.H{
+		"client": gin.H{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"scopes":           strings.Fields(req.Scope),
+		"redirect_uri":     redirectURI,
+		"consent_required": consentRequired,
+	})
+}
+
+// ApproveAuthorization records the user's answer to an authorization request
+// and returns where to send the browser next
+func (ctrl *OAuthController) ApproveAuthorization(c *gin.Context) {
+	var req ApproveAuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	client, redirectURI, err := ctrl.serverService.ValidateAuthorization(&req.AuthorizationRequest)
+	if err != nil {
+		authorizationErrorResponseWithRedirect(c, err, redirectURI, req.State)
+		return
+	}
+
+	if !req.Approve {
+		utils.SuccessResponse(c, http.StatusOK, "Authorization denied", gin.H{
+			"redirect_to": services.AuthorizationErrorRedirect(redirectURI, services.ErrAccessDenied, req.State),
+		})
+		return
+	}
+
+	redirectTo, err := ctrl.serverService.Authorize(c.GetUint("user_id"), c.GetTime("session_created_at"), client, redirectURI, &req.AuthorizationRequest)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to authorize client", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Authorization granted", gin.H{
+		"redirect_to": redirectTo,
+	})
+}
+
+// Token is the OAuth 2.0 token endpoint. It answers in the format defined by
+// RFC 6749 rather than the API's usual envelope.
+func (ctrl *OAuthController) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req services.TokenRequest
+	if err := c.ShouldBindWith(&req, binding.FormPost); err != nil {
+		oauthErrorResponse(c, services.ErrInvalidOAuthRequest)
+		return
+	}
+
+	client, ok := ctrl.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	response, err := ctrl.serverService.Token(client, &req)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect is the RFC 7662 token introspection endpoint for resource
+// servers that cannot validate tokens themselves
+func (ctrl *OAuthController) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, ok := ctrl.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthErrorResponse(c, services.ErrInvalidOAuthRequest)
+		return
+	}
+
+	response, err := ctrl.serverService.Introspect(client, token, c.PostForm("token_type_hint"))
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke is the RFC 7009 token revocation endpoint. Clients may only revoke
+// their own tokens and get the same response for unknown tokens.
+func (ctrl *OAuthController) Revoke(c *gin.Context) {
+	client, ok := ctrl.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthErrorResponse(c, services.ErrInvalidOAuthRequest)
+		return
+	}
+
+	if err := ctrl.serverService.RevokeToken(client, token, c.PostForm("token_type_hint")); err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// DeviceAuthorization is the RFC 8628 device authorization endpoint. The
+// device shows the user code and polls the token endpoint while the user
+// approves it.
+func (ctrl *OAuthController) DeviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	client, ok := ctrl.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	response, err := ctrl.serverService.DeviceAuthorization(client, c.PostForm("scope"))
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetDeviceAuthorization describes the device authorization a user code
+// refers to, for the page where the user enters it
+func (ctrl *OAuthController) GetDeviceAuthorization(c *gin.Context) {
+	device, client, err := ctrl.serverService.PendingDevice(c.Query("user_code"))
+	if err != nil {
+		deviceErrorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Device authorization is pending", gin.H{
+		"client": gin.H{
+			"client_id": client.ClientID,
+			"name":      client.Name,
+		},
+		"scopes":     strings.Fields(device.Scope),
+		"expires_at": device.ExpiresAt,
+	})
+}
+
+// DecideDeviceAuthorization records the user's answer to a device
+// authorization
+func (ctrl *OAuthController) DecideDeviceAuthorization(c *gin.Context) {
+	var req DeviceDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := ctrl.serverService.DecideDevice(c.GetUint("user_id"), c.GetTime("session_created_at"), req.UserCode, req.Approve); err != nil {
+		deviceErrorResponse(c, err)
+		return
+	}
+
+	if !req.Approve {
+		utils.SuccessResponse(c, http.StatusOK, "Device authorization denied", nil)
+		return
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Device authorized, return to your device to continue", nil)
+}
+
+// UserInfo returns the OpenID Connect claims about the token's user
+func (ctrl *OAuthController) UserInfo(c *gin.Context) {
+	userInfo, err := ctrl.serverService.UserInfo(c.GetUint("user_id"), c.GetString("oauth_scope"))
+	if errors.Is(err, services.ErrInvalidGrant) {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth", error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userInfo)
+}
+
+// EndSession is the OpenID Connect RP-initiated logout endpoint. Valid
+// requests are handed to the frontend, which signs the user out.
+func (ctrl *OAuthController) EndSession(c *gin.Context) {
+	var req services.LogoutRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid logout request", err.Error())
+		return
+	}
+
+	if _, _, err := ctrl.serverService.ValidateLogout(&req); err != nil {
+		logoutErrorResponse(c, err)
+		return
+	}
+
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"id_token_hint":            req.IDTokenHint,
+		"client_id":                req.ClientID,
+		"post_logout_redirect_uri": req.PostLogoutRedirectURI,
+		"state":                    req.State,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	c.Redirect(http.StatusFound, config.AppConfig.FrontendURL+"/oauth/logout?"+params.Encode())
+}
+
+// ConfirmLogout signs the user out at the request of an OAuth client and
+// returns where to send the browser next
+func (ctrl *OAuthController) ConfirmLogout(c *gin.Context) {
+	var req services.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	userID, redirectTo, err := ctrl.serverService.ValidateLogout(&req)
+	if err != nil {
+		logoutErrorResponse(c, err)
+		return
+	}
+	if userID != 0 && userID != c.GetUint("user_id") {
+		utils.ErrorResponse(c, http.StatusBadRequest, "ID token hint belongs to another user", "invalid_id_token_hint")
+		return
+	}
+
+	if err := endCurrentSession(c, ctrl.sessionService); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to logout", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Logged out successfully", gin.H{
+		"redirect_to": redirectTo,
+	})
+}
+
+// ListConsents returns the OAuth clients the authenticated user has authorized
+func (ctrl *OAuthController) ListConsents(c *gin.Context) {
+	consents, err := ctrl.serverService.ListConsents(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve authorized apps", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Authorized apps retrieved successfully", consents)
+}
+
+// RevokeConsent withdraws access granted to an OAuth client
+func (ctrl *OAuthController) RevokeConsent(c *gin.Context) {
+	err := ctrl.serverService.RevokeConsent(c.GetUint("user_id"), c.Param("client_id"))
+	if errors.Is(err, services.ErrConsentNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Authorized app not found", err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke access", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Access revoked successfully", nil)
+}
+
+// authenticateClient identifies the client of a token request using HTTP
+// Basic authentication or the client_id and client_secret form parameters,
+// writing an error response on failure
+func (ctrl *OAuthController) authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 form-encodes the credentials before Basic encoding them
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err == nil {
+			secret, err = url.QueryUnescape(secret)
+		}
+		if err != nil {
+			oauthErrorResponse(c, services.ErrInvalidClient)
+			return nil, false
+		}
+
+		// Clients must not use more than one authentication method
+		if formClientID := c.PostForm("client_id"); (formClientID != "" && formClientID != clientID) || c.PostForm("client_secret") != "" {
+			oauthErrorResponse(c, services.ErrInvalidOAuthRequest)
+			return nil, false
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	if clientID == "" {
+		oauthErrorResponse(c, services.ErrInvalidClient)
+		return nil, false
+	}
+
+	client, err := ctrl.clientService.Authenticate(clientID, secret)
+	if err != nil {
+		oauthErrorResponse(c, err)
+		return nil, false
+	}
+
+	return client, true
+}
+
+// oauthErrorResponse writes an RFC 6749 error response
+func oauthErrorResponse(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.ErrInvalidClient.Code {
+		status = http.StatusUnauthorized
+		if c.GetHeader("Authorization") != "" {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+
+	response := gin.H{"error": oauthErr.Code}
+	if oauthErr.Description != "" {
+		response["error_description"] = oauthErr.Description
+	}
+	c.JSON(status, response)
+}
+
+// authorizationErrorResponse reports an authorization request that cannot
+// be answered through the client's redirect URI
+func authorizationErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidClient):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unknown OAuth client", services.ErrInvalidClient.Code)
+	case errors.Is(err, services.ErrInvalidClientRedirect):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Redirect URI is not registered for this client", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate authorization request", err.Error())
+	}
+}
+
+// deviceErrorResponse maps device authorization errors to responses
+func deviceErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidUserCode) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired user code", err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process device authorization", err.Error())
+}
+
+// logoutErrorResponse reports an invalid RP-initiated logout request
+func logoutErrorResponse(c *gin.Context, err error) {
+	var oauthErr *services.OAuthError
+	switch {
+	case errors.Is(err, services.ErrInvalidClientRedirect):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Post logout redirect URI is not registered for this client", err.Error())
+	case errors.As(err, &oauthErr):
+		utils.ErrorResponse(c, http.StatusBadRequest, oauthErr.Description, oauthErr.Code)
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate logout request", err.Error())
+	}
+}
+
+// authorizationErrorResponseWithRedirect reports an invalid authorization
+// request, including where to send the browser when the client can be told
+func authorizationErrorResponseWithRedirect(c *gin.Context, err error, redirectURI, state string) {
+	var oauthErr *services.OAuthError
+	if redirectURI == "" || !errors.As(err, &oauthErr) {
+		authorizationErrorResponse(c, err)
+		return
+	}
+
+	utils.ErrorResponseWithData(c, http.StatusBadRequest, "Invalid authorization request", oauthErr.Code, gin.H{
+		"error_description": oauthErr.Description,
+		"redirect_to":       services.AuthorizationErrorRedirect(redirectURI, err, state),
+	})
+}
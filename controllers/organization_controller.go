@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type OrganizationController struct {
+	organizationService *services.OrganizationService
+	tokenService        *services.TokenService
+}
+
+// NewOrganizationController creates a new organization controller
+func NewOrganizationController() *OrganizationController {
+	return &OrganizationController{
+		organizationService: services.NewOrganizationService(),
+		tokenService:        services.NewTokenService(),
+	}
+}
+
+// CreateOrganizationRequest represents create organization request body
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,max=63"`
+}
+
+// UpdateOrganizationRequest represents update organization request body
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// SwitchOrganizationRequest represents switch organization request body.
+// An organization ID of 0 leaves the current organization.
+type SwitchOrganizationRequest struct {
+	OrganizationID uint `json:"organization_id"`
+}
+
+// InviteMemberRequest represents invite member request body
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// AcceptInvitationRequest represents accept invitation request body
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UpdateMemberRequest represents update member request body
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListOrganizations returns the organizations the user belongs to
+func (ctrl *OrganizationController) ListOrganizations(c *gin.Context) {
+	memberships, err := ctrl.organizationService.ListForUser(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve organizations", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organizations retrieved successfully", memberships)
+}
+
+// CreateOrganization adds an organization owned by the user
+func (ctrl *OrganizationController) CreateOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	organization, err := ctrl.organizationService.Create(c.GetUint("user_id"), req.Name, req.Slug)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to create organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Organization created successfully", organization)
+}
+
+// SwitchOrganization changes the session's active organization and returns
+// tokens carrying it. The current access token is revoked.
+func (ctrl *OrganizationController) SwitchOrganization(c *gin.Context) {
+	var req SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found", err.Error())
+		return
+	}
+
+	tokens, err := ctrl.tokenService.SwitchOrganization(&user, c.GetString("session_id"), req.OrganizationID)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to switch organization")
+		return
+	}
+
+	if err := services.Revocations.Revoke(c.GetString("token_id"), user.ID, c.GetTime("token_expires_at")); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to switch organization", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization switched successfully", tokenResponse(&user, tokens))
+}
+
+// GetOrganization returns the active organization and the user's role in it
+func (ctrl *OrganizationController) GetOrganization(c *gin.Context) {
+	organization, err := ctrl.organizationService.Get(c.GetUint("organization_id"))
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to retrieve organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization retrieved successfully", gin.H{
+		"organization": organization,
+		"role":         c.GetString("organization_role"),
+	})
+}
+
+// UpdateOrganization renames the active organization
+func (ctrl *OrganizationController) UpdateOrganization(c *gin.Context) {
+	var req UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	organization, err := ctrl.organizationService.Update(c.GetUint("organization_id"), req.Name)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to update organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization updated successfully", organization)
+}
+
+// DeleteOrganization removes the active organization
+func (ctrl *OrganizationController) DeleteOrganization(c *gin.Context) {
+	if err := ctrl.organizationService.Delete(c.GetUint("organization_id")); err != nil {
+		organizationErrorResponse(c, err, "Failed to delete organization")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Organization deleted successfully", nil)
+}
+
+// ListMembers returns the active organization's members
+func (ctrl *OrganizationController) ListMembers(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+
+	members, err := ctrl.organizationService.ListMembers(c.GetUint("organization_id"), pagination)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve members", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Members retrieved successfully", gin.H{
+		"members":    members,
+		"pagination": pagination,
+	})
+}
+
+// InviteMember emails an invitation to join the active organization
+func (ctrl *OrganizationController) InviteMember(c *gin.Context) {
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	invitation, token, err := ctrl.organizationService.Invite(c.GetUint("organization_id"), c.GetUint("user_id"), c.GetString("organization_role"), req.Email, req.Role)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to send invitation")
+		return
+	}
+	ctrl.organizationService.SendInvitation(invitation, token)
+
+	utils.SuccessResponse(c, http.StatusCreated, "Invitation sent successfully", invitation)
+}
+
+// ListInvitations returns the active organization's pending invitations
+func (ctrl *OrganizationController) ListInvitations(c *gin.Context) {
+	invitations, err := ctrl.organizationService.ListInvitations(c.GetUint("organization_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve invitations", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invitations retrieved successfully", invitations)
+}
+
+// RevokeInvitation withdraws an invitation to the active organization
+func (ctrl *OrganizationController) RevokeInvitation(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.organizationService.RevokeInvitation(c.GetUint("organization_id"), id); err != nil {
+		organizationErrorResponse(c, err, "Failed to revoke invitation")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Invitation revoked successfully", nil)
+}
+
+// AcceptInvitation joins the organization the user was invited to
+func (ctrl *OrganizationController) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.GetUint("user_id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found", err.Error())
+		return
+	}
+
+	membership, err := ctrl.organizationService.AcceptInvitation(&user, req.Token)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to accept invitation")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Invitation accepted successfully", membership)
+}
+
+// UpdateMember changes a member's role in the active organization
+func (ctrl *OrganizationController) UpdateMember(c *gin.Context) {
+	userID, ok := idParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	membership, err := ctrl.organizationService.UpdateMember(c.GetUint("organization_id"), c.GetString("organization_role"), userID, req.Role)
+	if err != nil {
+		organizationErrorResponse(c, err, "Failed to update member")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Member updated successfully", membership)
+}
+
+// RemoveMember removes a member from the active organization, or lets the
+// user leave it
+func (ctrl *OrganizationController) RemoveMember(c *gin.Context) {
+	userID, ok := idParam(c, "user_id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.organizationService.RemoveMember(c.GetUint("organization_id"), c.GetUint("user_id"), c.GetString("organization_role"), userID); err != nil {
+		organizationErrorResponse(c, err, "Failed to remove member")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Member removed successfully", nil)
+}
+
+// organizationErrorResponse maps organization errors to responses
+func organizationErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Organization not found", err.Error())
+	case errors.Is(err, services.ErrMembershipNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Membership not found", err.Error())
+	case errors.Is(err, services.ErrInvitationNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Invitation not found or expired", err.Error())
+	case errors.Is(err, services.ErrInvitationEmailMismatch):
+		utils.ErrorResponse(c, http.StatusForbidden, "This invitation was sent to a different email address", err.Error())
+	case errors.Is(err, services.ErrOrganizationSlugTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "Organization slug is already taken", err.Error())
+	case errors.Is(err, services.ErrAlreadyMember):
+		utils.ErrorResponse(c, http.StatusConflict, "User is already a member of this organization", err.Error())
+	case errors.Is(err, services.ErrInvalidOrganizationSlug):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Slug may only contain lowercase letters, numbers and single hyphens", err.Error())
+	case errors.Is(err, services.ErrInvalidOrgRole):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Role must be owner, admin or member", err.Error())
+	case errors.Is(err, services.ErrLastOwner):
+		utils.ErrorResponse(c, http.StatusBadRequest, "An organization must keep at least one owner", err.Error())
+	case errors.Is(err, services.ErrOrgRoleNotAllowed):
+		utils.ErrorResponse(c, http.StatusForbidden, "Your organization role does not allow this change", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type PasskeyController struct {
+	webauthnService *services.WebAuthnService
+}
+
+// NewPasskeyController creates a new passkey controller
+func NewPasskeyController() *PasskeyController {
+	return &PasskeyController{
+		webauthnService: services.NewWebAuthnService(),
+	}
+}
+
+// FinishPasskeyRegistrationRequest represents the result of creating a passkey
+type FinishPasskeyRegistrationRequest struct {
+	SessionToken string                    `json:"session_token" binding:"required"`
+	Name         string                    `json:"name" binding:"max=100"`
+	Credential   utils.PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// RenamePasskeyRequest represents passkey rename request body
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// ListPasskeys returns the authenticated user's passkeys
+func (ctrl *PasskeyController) ListPasskeys(c *gin.Context) {
+	passkeys, err := ctrl.webauthnService.List(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve passkeys", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkeys retrieved successfully", passkeys)
+}
+
+// BeginRegistration returns the options for creating a new passkey
+func (ctrl *PasskeyController) BeginRegistration(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	options, sessionToken, err := ctrl.webauthnService.BeginRegistration(user)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start passkey registration", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Create a passkey with your authenticator", gin.H{
+		"options":       options,
+		"session_token": sessionToken,
+	})
+}
+
+// FinishRegistration verifies and stores a newly created passkey
+func (ctrl *PasskeyController) FinishRegistration(c *gin.Context) {
+	var req FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	passkey, err := ctrl.webauthnService.FinishRegistration(user, req.SessionToken, req.Name, &req.Credential)
+	if err != nil {
+		passkeyErrorResponse(c, err, "Failed to register passkey")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Passkey registered successfully", passkey)
+}
+
+// RenamePasskey changes the name of one of the user's passkeys
+func (ctrl *PasskeyController) RenamePasskey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid passkey ID", err.Error())
+		return
+	}
+
+	var req RenamePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	passkey, err := ctrl.webauthnService.Rename(c.GetUint("user_id"), uint(id), req.Name)
+	if err != nil {
+		passkeyErrorResponse(c, err, "Failed to rename passkey")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey renamed successfully", passkey)
+}
+
+// DeletePasskey removes one of the user's passkeys
+func (ctrl *PasskeyController) DeletePasskey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid passkey ID", err.Error())
+		return
+	}
+
+	if err := ctrl.webauthnService.Delete(c.GetUint("user_id"), uint(id)); err != nil {
+		passkeyErrorResponse(c, err, "Failed to delete passkey")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Passkey deleted successfully", nil)
+}
+
+// passkeyErrorResponse maps WebAuthn service errors to responses
+func passkeyErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebAuthnSession):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired passkey session, please try again", err.Error())
+	case errors.Is(err, utils.ErrWebAuthnVerification):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Passkey verification failed", utils.ErrWebAuthnVerification.Error())
+	case errors.Is(err, services.ErrPasskeySignCount):
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Passkey signature counter is invalid, the passkey may have been cloned", err.Error())
+	case errors.Is(err, services.ErrPasskeyNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Passkey not found", err.Error())
+	case errors.Is(err, services.ErrPasskeyAlreadyRegistered):
+		utils.ErrorResponse(c, http.StatusConflict, "Passkey is already registered", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
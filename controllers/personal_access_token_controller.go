@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type PersonalAccessTokenController struct {
+	patService *services.PersonalAccessTokenService
+}
+
+// NewPersonalAccessTokenController creates a new personal access token controller
+func NewPersonalAccessTokenController() *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		patService: services.NewPersonalAccessTokenService(),
+	}
+}
+
+// CreatePersonalAccessTokenRequest represents personal access token request body
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// PersonalAccessTokenSecretResponse includes the token secret, shown only once
+type PersonalAccessTokenSecretResponse struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// ListTokens returns the authenticated user's personal access tokens
+func (ctrl *PersonalAccessTokenController) ListTokens(c *gin.Context) {
+	tokens, err := ctrl.patService.List(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve personal access tokens", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Personal access tokens retrieved successfully", tokens)
+}
+
+// CreateToken issues a personal access token to the authenticated user
+func (ctrl *PersonalAccessTokenController) CreateToken(c *gin.Context) {
+	var req CreatePersonalAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	token, secret, err := ctrl.patService.Create(c.GetUint("user_id"), services.PersonalAccessTokenInput{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		personalAccessTokenErrorResponse(c, err, "Failed to create personal access token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Personal access token created successfully, store it now as it will not be shown again", PersonalAccessTokenSecretResponse{
+		PersonalAccessToken: *token,
+		Token:               secret,
+	})
+}
+
+// DeleteToken revokes one of the user's personal access tokens
+func (ctrl *PersonalAccessTokenController) DeleteToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid token ID", err.Error())
+		return
+	}
+
+	if err := ctrl.patService.Delete(c.GetUint("user_id"), uint(id)); err != nil {
+		personalAccessTokenErrorResponse(c, err, "Failed to revoke personal access token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Personal access token revoked successfully", nil)
+}
+
+// personalAccessTokenErrorResponse maps personal access token service errors to responses
+func personalAccessTokenErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrPersonalAccessTokenNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Personal access token not found", err.Error())
+	case errors.Is(err, services.ErrInvalidTokenScope):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Scopes must be profile scopes or permissions you have", err.Error())
+	case errors.Is(err, services.ErrInvalidTokenExpiry):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Expiry must be in the future", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
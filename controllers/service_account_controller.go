@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type ServiceAccountController struct {
+	serviceAccountService *services.ServiceAccountService
+}
+
+// NewServiceAccountController creates a new service account admin controller
+func NewServiceAccountController() *ServiceAccountController {
+	return &ServiceAccountController{
+		serviceAccountService: services.NewServiceAccountService(),
+	}
+}
+
+// ServiceAccountRequest represents service account request body
+type ServiceAccountRequest struct {
+	Name           string   `json:"name" binding:"required,max=100"`
+	Description    string   `json:"description" binding:"max=255"`
+	Permissions    []string `json:"permissions"`
+	OrganizationID *uint    `json:"organization_id"`
+}
+
+// CreateAPIKeyRequest represents API key request body
+type CreateAPIKeyRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest represents API key rotation request body
+type RotateAPIKeyRequest struct {
+	ExpiresAt    *time.Time `json:"expires_at"`
+	GraceMinutes int        `json:"grace_minutes" binding:"min=0,max=10080"`
+}
+
+// APIKeySecretResponse includes the API key secret, shown only once
+type APIKeySecretResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// ListServiceAccounts returns the service accounts
+func (ctrl *ServiceAccountController) ListServiceAccounts(c *gin.Context) {
+	pagination := utils.GetPagination(c)
+
+	accounts, err := ctrl.serviceAccountService.List(pagination)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve service accounts", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service accounts retrieved successfully", gin.H{
+		"service_accounts": accounts,
+		"pagination":       pagination,
+	})
+}
+
+// GetServiceAccount returns a single service account
+func (ctrl *ServiceAccountController) GetServiceAccount(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	account, err := ctrl.serviceAccountService.Get(id)
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to retrieve service account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service account retrieved successfully", account)
+}
+
+// CreateServiceAccount adds a service account owned by the current admin and,
+// optionally, an organization
+func (ctrl *ServiceAccountController) CreateServiceAccount(c *gin.Context) {
+	var req ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	account, err := ctrl.serviceAccountService.Create(c.GetUint("user_id"), c.GetStringSlice("user_roles"), req.input())
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to create service account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Service account created successfully", account)
+}
+
+// UpdateServiceAccount replaces a service account's settings
+func (ctrl *ServiceAccountController) UpdateServiceAccount(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	var req ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	account, err := ctrl.serviceAccountService.Update(id, c.GetUint("user_id"), c.GetStringSlice("user_roles"), req.input())
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to update service account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service account updated successfully", account)
+}
+
+// DeleteServiceAccount removes a service account and its API keys
+func (ctrl *ServiceAccountController) DeleteServiceAccount(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.serviceAccountService.Delete(id); err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to delete service account")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Service account deleted successfully", nil)
+}
+
+// ListAPIKeys returns a service account's API keys
+func (ctrl *ServiceAccountController) ListAPIKeys(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	keys, err := ctrl.serviceAccountService.ListKeys(id)
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to retrieve API keys")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+// CreateAPIKey issues an API key to a service account
+func (ctrl *ServiceAccountController) CreateAPIKey(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	key, secret, err := ctrl.serviceAccountService.CreateKey(id, req.ExpiresAt)
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to create API key")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "API key created successfully, store it now as it will not be shown again", APIKeySecretResponse{
+		APIKey: *key,
+		Key:    secret,
+	})
+}
+
+// RotateAPIKey replaces an API key, optionally keeping the old one valid for
+// a grace period
+func (ctrl *ServiceAccountController) RotateAPIKey(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+	keyID, ok := idParam(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	key, secret, err := ctrl.serviceAccountService.RotateKey(id, keyID, req.ExpiresAt, time.Duration(req.GraceMinutes)*time.Minute)
+	if err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to rotate API key")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API key rotated successfully, store it now as it will not be shown again", APIKeySecretResponse{
+		APIKey: *key,
+		Key:    secret,
+	})
+}
+
+// DeleteAPIKey revokes one of a service account's API keys
+func (ctrl *ServiceAccountController) DeleteAPIKey(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid service account ID")
+	if !ok {
+		return
+	}
+	keyID, ok := idParam(c, "key_id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	if err := ctrl.serviceAccountService.DeleteKey(id, keyID); err != nil {
+		serviceAccountErrorResponse(c, err, "Failed to revoke API key")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "API key revoked successfully", nil)
+}
+
+// input converts the request into service account settings
+func (req ServiceAccountRequest) input() services.ServiceAccountInput {
+	return services.ServiceAccountInput{
+		Name:           req.Name,
+		Description:    req.Description,
+		Permissions:    req.Permissions,
+		OrganizationID: req.OrganizationID,
+	}
+}
+
+// idParam parses a numeric path parameter, writing an error response when it
+// is invalid
+func idParam(c *gin.Context, name, invalidMessage string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, invalidMessage, "invalid_id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// serviceAccountErrorResponse maps service account errors to responses
+func serviceAccountErrorResponse(c *gin.Context, err error, failedMessage string) {
+	switch {
+	case errors.Is(err, services.ErrServiceAccountNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Service account not found", err.Error())
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "API key not found", err.Error())
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Organization not found", err.Error())
+	case errors.Is(err, services.ErrInvalidServiceAccountGrant):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Permissions must exist and be granted by your own roles", err.Error())
+	case errors.Is(err, services.ErrInvalidTokenExpiry):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Expiry must be in the future", err.Error())
+	case errors.Is(err, services.ErrInvalidGracePeriod):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Grace period cannot be negative", err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, failedMessage, err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type SessionController struct {
+	sessionService *services.SessionService
+}
+
+// NewSessionController creates a new session controller
+func NewSessionController() *SessionController {
+	return &SessionController{
+		sessionService: services.NewSessionService(),
+	}
+}
+
+// SessionResponse represents a session in API responses
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the authenticated user's active sessions
+func (ctrl *SessionController) ListSessions(c *gin.Context) {
+	sessions, err := ctrl.sessionService.List(c.GetUint("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve sessions", err.Error())
+		return
+	}
+
+	currentSessionID := c.GetString("session_id")
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			Session: session,
+			Current: session.ID == currentSessionID,
+		})
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Sessions retrieved successfully", response)
+}
+
+// RevokeSession signs out one of the authenticated user's sessions
+func (ctrl *SessionController) RevokeSession(c *gin.Context) {
+	if err := ctrl.sessionService.Revoke(c.GetUint("user_id"), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Session not found", err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Session revoked successfully", nil)
+}
+
+// RevokeOtherSessions signs out every session except the current one
+func (ctrl *SessionController) RevokeOtherSessions(c *gin.Context) {
+	if err := ctrl.sessionService.RevokeOthers(c.GetUint("user_id"), c.GetString("session_id")); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions", err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Signed out of all other sessions", nil)
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+type WellKnownController struct {
+	oauthServerService *services.OAuthServerService
+}
+
+// NewWellKnownController creates a new well-known controller
+func NewWellKnownController() *WellKnownController {
+	return &WellKnownController{
+		oauthServerService: services.NewOAuthServerService(),
+	}
+}
+
+// JWKS publishes the public keys used to verify issued tokens
+func (ctrl *WellKnownController) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
+
+// OpenIDConfiguration publishes the OpenID Connect discovery document
+func (ctrl *WellKnownController) OpenIDConfiguration(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, ctrl.oauthServerService.Discovery())
+}
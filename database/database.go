@@ -43,11 +43,40 @@ func ConnectDatabase() {
 	log.Println("Database connected successfully")
 
 	// Auto migrate models
-	if err := DB.AutoMigrate(&models.User{}); err != nil {
+	if err := DB.AutoMigrate(
+		&models.User{},
+		&models.Role{},
+		&models.Permission{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.RecoveryCode{},
+		&models.OneTimeToken{},
+		&models.PasswordHistory{},
+		&models.WebAuthnCredential{},
+		&models.ExternalIdentity{},
+		&models.OAuthClient{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthRefreshToken{},
+		&models.OAuthAccessToken{},
+		&models.OAuthDeviceCode{},
+		&models.OAuthConsent{},
+		&models.PersonalAccessToken{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.Organization{},
+		&models.OrganizationMembership{},
+		&models.OrganizationInvitation{},
+	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
 	log.Println("Database migration completed")
+
+	// Seed built-in roles and permissions
+	if err := seedRoles(); err != nil {
+		log.Fatal("Failed to seed roles:", err)
+	}
 }
 
 // GetDB returns database instance
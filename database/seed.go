@@ -0,0 +1,94 @@
+package database
+
+import (
+	"log"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+// defaultPermissions lists the permissions every deployment starts with
+var defaultPermissions = []models.Permission{
+	{Name: models.PermissionUsersRead, Description: "View user accounts"},
+	{Name: models.PermissionUsersWrite, Description: "Create, update and disable user accounts"},
+	{Name: models.PermissionOAuthClientsRead, Description: "View registered OAuth clients"},
+	{Name: models.PermissionOAuthClientsWrite, Description: "Register, update and delete OAuth clients"},
+	{Name: models.PermissionServiceAccountsRead, Description: "View service accounts and their API keys"},
+	{Name: models.PermissionServiceAccountsWrite, Description: "Manage service accounts and issue API keys"},
+}
+
+// defaultRoles lists the built-in roles and the permissions granted to them
+var defaultRoles = []struct {
+	Role        models.Role
+	Permissions []string
+}{
+	{
+		Role: models.Role{Name: models.RoleAdmin, Description: "Full administrative access"},
+		Permissions: []string{
+			models.PermissionUsersRead,
+			models.PermissionUsersWrite,
+			models.PermissionOAuthClientsRead,
+			models.PermissionOAuthClientsWrite,
+			models.PermissionServiceAccountsRead,
+			models.PermissionServiceAccountsWrite,
+		},
+	},
+	{
+		Role: models.Role{Name: models.RoleUser, Description: "Default role for registered users"},
+	},
+}
+
+// seedRoles ensures the built-in roles and permissions exist and grants the
+// admin role to the verified accounts listed in ADMIN_EMAILS
+func seedRoles() error {
+	for _, permission := range defaultPermissions {
+		if err := DB.Where(models.Permission{Name: permission.Name}).
+			Attrs(models.Permission{Description: permission.Description}).
+			FirstOrCreate(&permission).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range defaultRoles {
+		role := entry.Role
+		if err := DB.Where(models.Role{Name: role.Name}).
+			Attrs(models.Role{Description: role.Description}).
+			FirstOrCreate(&role).Error; err != nil {
+			return err
+		}
+
+		if len(entry.Permissions) == 0 {
+			continue
+		}
+
+		var permissions []models.Permission
+		if err := DB.Where("name IN ?", entry.Permissions).Find(&permissions).Error; err != nil {
+			return err
+		}
+		if err := DB.Model(&role).Association("Permissions").Append(&permissions); err != nil {
+			return err
+		}
+	}
+
+	if len(config.AppConfig.AdminEmails) == 0 {
+		return nil
+	}
+
+	var admin models.Role
+	if err := DB.Where("name = ?", models.RoleAdmin).First(&admin).Error; err != nil {
+		return err
+	}
+
+	var users []models.User
+	if err := DB.Where("email IN ? AND is_email_verified = ?", config.AppConfig.AdminEmails, true).Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := DB.Model(&user).Association("Roles").Append(&admin); err != nil {
+			return err
+		}
+		log.Printf("Granted admin role to %s", user.Email)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package webauthntest provides a software authenticator for driving WebAuthn
+// registration and assertion ceremonies in tests
+package webauthntest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// Authenticator flags
+const (
+	FlagUserPresent  byte = 0x01
+	FlagUserVerified byte = 0x04
+	flagAttestedData byte = 0x40
+)
+
+// Authenticator is an in-process authenticator holding a single credential.
+// Its fields may be changed between ceremonies to produce invalid responses.
+type Authenticator struct {
+	// RPID is hashed into the authenticator data
+	RPID string
+	// Origin is reported in the client data
+	Origin string
+	// Flags are reported in the authenticator data
+	Flags byte
+	// SignCount is incremented before every assertion
+	SignCount uint32
+
+	CredentialID []byte
+	UserHandle   []byte
+
+	algorithm int64
+	signer    crypto.Signer
+}
+
+// NewES256 creates an authenticator with a P-256 ECDSA credential
+func NewES256(rpID, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return newAuthenticator(rpID, origin, utils.COSEAlgES256, key)
+}
+
+// NewEdDSA creates an authenticator with an Ed25519 credential
+func NewEdDSA(rpID, origin string) *Authenticator {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return newAuthenticator(rpID, origin, utils.COSEAlgEdDSA, key)
+}
+
+func newAuthenticator(rpID, origin string, algorithm int64, signer crypto.Signer) *Authenticator {
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		panic(err)
+	}
+
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Flags:        FlagUserPresent | FlagUserVerified,
+		CredentialID: credentialID,
+		algorithm:    algorithm,
+		signer:       signer,
+	}
+}
+
+// Create answers navigator.credentials.create() with attestation "none"
+func (a *Authenticator) Create(challenge, userHandle string) *utils.PublicKeyCredential {
+	a.UserHandle, _ = utils.DecodeBase64URL(userHandle)
+
+	authData := a.authenticatorData(a.Flags | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.PublicKeyCOSE()...)
+
+	attestationObject := EncodeCBOR(Map{
+		"fmt", "none",
+		"attStmt", Map{},
+		"authData", authData,
+	})
+
+	return &utils.PublicKeyCredential{
+		ID:    utils.EncodeBase64URL(a.CredentialID),
+		RawID: utils.EncodeBase64URL(a.CredentialID),
+		Type:  "public-key",
+		Response: utils.AuthenticatorResponse{
+			ClientDataJSON:    utils.EncodeBase64URL(a.ClientData(utils.WebAuthnCeremonyCreate, challenge)),
+			AttestationObject: utils.EncodeBase64URL(attestationObject),
+			Transports:        []string{"internal"},
+		},
+	}
+}
+
+// Get answers navigator.credentials.get(), advancing the signature counter
+func (a *Authenticator) Get(challenge string) *utils.PublicKeyCredential {
+	a.SignCount++
+
+	authData := a.authenticatorData(a.Flags)
+	clientData := a.ClientData(utils.WebAuthnCeremonyGet, challenge)
+
+	return &utils.PublicKeyCredential{
+		ID:    utils.EncodeBase64URL(a.CredentialID),
+		RawID: utils.EncodeBase64URL(a.CredentialID),
+		Type:  "public-key",
+		Response: utils.AuthenticatorResponse{
+			ClientDataJSON:    utils.EncodeBase64URL(clientData),
+			AuthenticatorData: utils.EncodeBase64URL(authData),
+			Signature:         utils.EncodeBase64URL(a.Sign(authData, clientData)),
+			UserHandle:        utils.EncodeBase64URL(a.UserHandle),
+		},
+	}
+}
+
+// ClientData builds the client data JSON a browser would collect
+func (a *Authenticator) ClientData(ceremony, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.Origin,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return clientData
+}
+
+// Sign signs authenticator data and the hash of the client data
+func (a *Authenticator) Sign(authData, clientData []byte) []byte {
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	var (
+		signature []byte
+		err       error
+	)
+	switch a.algorithm {
+	case utils.COSEAlgES256:
+		digest := sha256.Sum256(signed)
+		signature, err = a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		signature, err = a.signer.Sign(rand.Reader, signed, crypto.Hash(0))
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// PublicKeyCOSE returns the credential public key in its COSE encoding
+func (a *Authenticator) PublicKeyCOSE() []byte {
+	switch publicKey := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		point, err := publicKey.Bytes()
+		if err != nil {
+			panic(err)
+		}
+		return EncodeCBOR(Map{
+			1, 2,
+			3, utils.COSEAlgES256,
+			-1, 1,
+			-2, point[1:33],
+			-3, point[33:],
+		})
+	case ed25519.PublicKey:
+		return EncodeCBOR(Map{
+			1, 1,
+			3, utils.COSEAlgEdDSA,
+			-1, 6,
+			-2, []byte(publicKey),
+		})
+	}
+	panic("webauthntest: unsupported key type")
+}
+
+// authenticatorData builds the fixed part of the authenticator data
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	authData := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(authData, a.SignCount)
+}
@@ -2,27 +2,61 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/middleware"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/routes"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
 )
 
 func main() {
 	// Load configuration
 	config.LoadConfig()
 
+	// Load JWT signing and verification keys
+	if err := utils.LoadSigningKeys(); err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+
+	// Configure the password hasher used for new passwords
+	if err := utils.LoadPasswordHasher(); err != nil {
+		log.Fatal("Failed to configure password hashing:", err)
+	}
+
+	// Load the list of common passwords rejected by the password policy
+	if err := services.LoadPasswordBlocklist(); err != nil {
+		log.Fatal("Failed to load password blocklist:", err)
+	}
+
+	// Register external OAuth2/OIDC sign-in providers
+	if err := services.LoadIdentityProviders(); err != nil {
+		log.Fatal("Failed to configure identity providers:", err)
+	}
+
 	// Set Gin mode
 	gin.SetMode(config.AppConfig.GinMode)
 
 	// Connect to database
 	database.ConnectDatabase()
 
+	// Load revoked tokens and purge expired tokens periodically
+	if err := services.Revocations.Load(); err != nil {
+		log.Fatal("Failed to load revoked tokens:", err)
+	}
+	services.StartCleanup(time.Duration(config.AppConfig.TokenPurgeMinutes) * time.Minute)
+
 	// Initialize Gin router
 	router := gin.Default()
 
+	// Only take the client IP from X-Forwarded-For when sent by a known proxy
+	if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		log.Fatal("Failed to configure trusted proxies:", err)
+	}
+
 	// Apply global middleware
 	router.Use(middleware.Logger())
 	router.Use(gin.Recovery())
@@ -31,7 +65,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// apiKeyFromRequest returns the API key sent in the X-API-Key header or as
+// "Authorization: ApiKey <key>"
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+
+	scheme, key, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "ApiKey") && key != "" {
+		return key, true
+	}
+	return "", false
+}
+
+// authenticateAPIKey validates a service account's API key. Requests made
+// with one have no user or session: user_id is 0, the account's permissions
+// are carried as token scopes and service_account_id identifies the caller.
+func authenticateAPIKey(c *gin.Context, serviceAccountService *services.ServiceAccountService, secret string) {
+	account, key, err := serviceAccountService.Authenticate(secret, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired API key", err.Error())
+		c.Abort()
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate API key", err.Error())
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", uint(0))
+	c.Set("user_email", "")
+	c.Set("user_roles", []string{})
+	c.Set("token_scopes", account.Permissions)
+	c.Set("service_account_id", account.ID)
+	c.Set("api_key_id", key.ID)
+
+	c.Next()
+}
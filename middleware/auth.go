@@ -1,16 +1,29 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
 )
 
-// AuthMiddleware validates JWT token
+// AuthMiddleware validates JWT access tokens, personal access tokens and
+// service account API keys
 func AuthMiddleware() gin.HandlerFunc {
+	sessionService := services.NewSessionService()
+	tokenService := services.NewTokenService()
+	patService := services.NewPersonalAccessTokenService()
+	serviceAccountService := services.NewServiceAccountService()
+
 	return func(c *gin.Context) {
+		if apiKey, ok := apiKeyFromRequest(c); ok {
+			authenticateAPIKey(c, serviceAccountService, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header required", "missing_token")
@@ -27,6 +40,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		token := parts[1]
+		if strings.HasPrefix(token, services.PersonalAccessTokenPrefix) {
+			authenticatePersonalAccessToken(c, patService, token)
+			return
+		}
+
 		claims, err := utils.ValidateToken(token)
 		if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token", err.Error())
@@ -34,10 +52,114 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if claims.TokenUse != utils.TokenUseAccess {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token", "invalid_token_use")
+			c.Abort()
+			return
+		}
+
+		// Tokens issued to OAuth clients are meant for other apps, not this API
+		if claims.ClientID != "" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token", "invalid_token_use")
+			c.Abort()
+			return
+		}
+
+		if claims.ID == "" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token", "missing_jti")
+			c.Abort()
+			return
+		}
+
+		// Reject tokens revoked by logout
+		revoked, err := services.Revocations.IsRevoked(claims.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate token", err.Error())
+			c.Abort()
+			return
+		}
+		if revoked {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Token has been revoked", "token_revoked")
+			c.Abort()
+			return
+		}
+
+		// Reject tokens issued before the user's tokens were invalidated, e.g.
+		// by a password change; deleted users have no version to match
+		version, err := tokenService.TokenVersion(claims.UserID)
+		if err != nil && !errors.Is(err, services.ErrTokenInvalidated) {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate token", err.Error())
+			c.Abort()
+			return
+		}
+		if err != nil || version != claims.TokenVersion {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Token is no longer valid, please login again", services.ErrTokenInvalidated.Error())
+			c.Abort()
+			return
+		}
+
+		// Reject tokens whose session has been signed out
+		session, err := sessionService.Touch(claims.SessionID, c.ClientIP())
+		if err != nil {
+			if errors.Is(err, services.ErrSessionNotFound) || errors.Is(err, services.ErrSessionRevoked) {
+				utils.ErrorResponse(c, http.StatusUnauthorized, "Session has ended, please login again", services.ErrSessionRevoked.Error())
+			} else {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate session", err.Error())
+			}
+			c.Abort()
+			return
+		}
+
 		// Set user information in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("user_roles", claims.Roles)
+		c.Set("session_id", claims.SessionID)
+		c.Set("session_created_at", session.CreatedAt)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Set("organization_id", claims.OrganizationID)
 
 		c.Next()
 	}
 }
+
+// RequireSession rejects requests authenticated with a personal access
+// token or an API key, keeping account management to signed-in users. It
+// must be used after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("session_id") == "" {
+			utils.ErrorResponse(c, http.StatusForbidden, "This action requires signing in", "session_required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticatePersonalAccessToken validates a personal access token. Requests
+// made with one carry its scopes, which RequireScope and RequirePermission
+// check, and have no session.
+func authenticatePersonalAccessToken(c *gin.Context, patService *services.PersonalAccessTokenService, secret string) {
+	token, user, roles, err := patService.Authenticate(secret, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidPersonalAccessToken) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token", err.Error())
+		c.Abort()
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to validate token", err.Error())
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user_email", user.Email)
+	c.Set("user_roles", roles)
+	c.Set("token_scopes", token.Scopes)
+	c.Set("personal_access_token_id", token.ID)
+
+	c.Next()
+}
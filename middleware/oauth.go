@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// OAuthTokenMiddleware validates access tokens issued to OAuth clients and
+// requires them to carry all of the given scopes. Errors are reported as
+// defined by RFC 6750.
+func OAuthTokenMiddleware(scopes ...string) gin.HandlerFunc {
+	tokenService := services.NewTokenService()
+
+	return func(c *gin.Context) {
+		scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="oauth"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid_request", "error_description": "Bearer token required"})
+			return
+		}
+
+		claims, err := utils.ValidateToken(token)
+		if err != nil || claims.TokenUse != utils.TokenUseAccess || claims.ClientID == "" || claims.ID == "" {
+			bearerError(c, http.StatusUnauthorized, "invalid_token", "Invalid or expired token")
+			return
+		}
+
+		revoked, err := services.Revocations.IsRevoked(claims.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+			return
+		}
+		if revoked {
+			bearerError(c, http.StatusUnauthorized, "invalid_token", "Token has been revoked")
+			return
+		}
+
+		// Tokens issued for a user die with the user's other tokens
+		if claims.UserID != 0 {
+			version, err := tokenService.TokenVersion(claims.UserID)
+			if err != nil && !errors.Is(err, services.ErrTokenInvalidated) {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+				return
+			}
+			if err != nil || version != claims.TokenVersion {
+				bearerError(c, http.StatusUnauthorized, "invalid_token", "Token is no longer valid")
+				return
+			}
+		}
+
+		granted := strings.Fields(claims.Scope)
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				bearerError(c, http.StatusForbidden, "insufficient_scope", "Token lacks the "+scope+" scope")
+				return
+			}
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("user_email", claims.Email)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Set("oauth_client_id", claims.ClientID)
+		c.Set("oauth_scope", claims.Scope)
+
+		c.Next()
+	}
+}
+
+// bearerError aborts the request with an RFC 6750 error
+func bearerError(c *gin.Context, status int, code, description string) {
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer realm="oauth", error=%q, error_description=%q`, code, description))
+	c.AbortWithStatusJSON(status, gin.H{"error": code, "error_description": description})
+}
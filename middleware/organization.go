@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// RequireOrganization scopes the request to the token's active organization,
+// which the user must still be a member of. Handlers read organization_id and
+// organization_role from the context. It must be used after AuthMiddleware.
+func RequireOrganization() gin.HandlerFunc {
+	organizationService := services.NewOrganizationService()
+
+	return func(c *gin.Context) {
+		organizationID := c.GetUint("organization_id")
+		if organizationID == 0 {
+			utils.ErrorResponse(c, http.StatusForbidden, "Switch to an organization first", "organization_required")
+			c.Abort()
+			return
+		}
+
+		// Membership is checked on every request so removals apply at once
+		membership, err := organizationService.Membership(organizationID, c.GetUint("user_id"))
+		if errors.Is(err, services.ErrMembershipNotFound) {
+			utils.ErrorResponse(c, http.StatusForbidden, "You are no longer a member of this organization", "not_organization_member")
+			c.Abort()
+			return
+		}
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check organization membership", err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Set("organization_role", membership.Role)
+
+		c.Next()
+	}
+}
+
+// RequireOrganizationRole allows the request if the user has any of the given
+// roles in the active organization. It must be used after RequireOrganization.
+func RequireOrganizationRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, c.GetString("organization_role")) {
+			utils.ErrorResponse(c, http.StatusForbidden, "You do not have access to this resource", "insufficient_organization_role")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,285 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// maxKeyBodyBytes limits how much of a request body is read to extract a key
+const maxKeyBodyBytes = 1 << 20
+
+// RateLimitResult is the outcome of taking one request from a limiter
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitAlgorithm decides whether a request is allowed, updating the
+// per-key state in place
+type RateLimitAlgorithm interface {
+	Take(state *RateLimitState, now time.Time) RateLimitResult
+	// Window is how long state must be kept after the last request
+	Window() time.Duration
+	// Policy describes the quota for the RateLimit-Policy header
+	Policy() string
+}
+
+// RateLimitKeyFunc derives the bucket key for a request. Returning false
+// skips rate limiting for the request.
+type RateLimitKeyFunc func(c *gin.Context) (string, bool)
+
+// RateLimitPolicy configures a rate limit applied to a route
+type RateLimitPolicy struct {
+	// Name namespaces keys so several policies can share a store
+	Name      string
+	Algorithm RateLimitAlgorithm
+	Key       RateLimitKeyFunc
+	// Store defaults to DefaultRateLimitStore
+	Store RateLimitStore
+}
+
+// RateLimit enforces a rate limit policy and sets the RateLimit-* headers
+func RateLimit(policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.AppConfig.RateLimitEnabled {
+			c.Next()
+			return
+		}
+
+		key, ok := policy.Key(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		store := policy.Store
+		if store == nil {
+			store = DefaultRateLimitStore
+		}
+
+		var result RateLimitResult
+		now := time.Now()
+		err := store.Update(c.Request.Context(), policy.Name+":"+key, policy.Algorithm.Window(), func(state *RateLimitState) {
+			result = policy.Algorithm.Take(state, now)
+		})
+		if err != nil {
+			// Fail open so an unavailable store does not take the API down
+			log.Printf("Rate limit store error for %s: %v", policy.Name, err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy.Algorithm.Policy())
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many requests, please try again later", "rate_limited")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// tokenBucket allows bursts up to the limit and refills continuously
+type tokenBucket struct {
+	limit  int
+	window time.Duration
+}
+
+// TokenBucket allows bursts of up to limit requests, refilling the bucket
+// at limit tokens per window
+func TokenBucket(limit int, window time.Duration) RateLimitAlgorithm {
+	return &tokenBucket{limit: limit, window: window}
+}
+
+func (t *tokenBucket) Take(state *RateLimitState, now time.Time) RateLimitResult {
+	capacity := float64(t.limit)
+	rate := capacity / t.window.Seconds()
+
+	if state.Timestamp.IsZero() {
+		state.Count = capacity
+	} else {
+		elapsed := now.Sub(state.Timestamp).Seconds()
+		state.Count = math.Min(capacity, state.Count+elapsed*rate)
+	}
+	state.Timestamp = now
+
+	result := RateLimitResult{Limit: t.limit}
+	if state.Count >= 1 {
+		state.Count--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - state.Count) / rate)
+	}
+
+	result.Remaining = int(state.Count)
+	result.Reset = secondsDuration((capacity - state.Count) / rate)
+	return result
+}
+
+func (t *tokenBucket) Window() time.Duration {
+	return t.window
+}
+
+func (t *tokenBucket) Policy() string {
+	return fmt.Sprintf("%d;w=%d", t.limit, ceilSeconds(t.window))
+}
+
+// slidingWindow approximates a sliding log by weighting the previous fixed
+// window's count by how much of it still overlaps the sliding window
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+}
+
+// SlidingWindow allows at most limit requests in any rolling window
+func SlidingWindow(limit int, window time.Duration) RateLimitAlgorithm {
+	return &slidingWindow{limit: limit, window: window}
+}
+
+func (s *slidingWindow) Take(state *RateLimitState, now time.Time) RateLimitResult {
+	windowStart := now.Truncate(s.window)
+
+	switch {
+	case state.Timestamp.Equal(windowStart):
+	case state.Timestamp.Add(s.window).Equal(windowStart):
+		state.PrevCount = state.Count
+		state.Count = 0
+	default:
+		state.PrevCount = 0
+		state.Count = 0
+	}
+	state.Timestamp = windowStart
+
+	elapsed := now.Sub(windowStart)
+	overlap := 1 - elapsed.Seconds()/s.window.Seconds()
+	used := state.PrevCount*overlap + state.Count
+
+	result := RateLimitResult{Limit: s.limit, Reset: windowStart.Add(s.window).Sub(now)}
+	if used+1 <= float64(s.limit) {
+		state.Count++
+		used++
+		result.Allowed = true
+	} else if state.PrevCount > 0 && state.Count < float64(s.limit) {
+		// Wait until enough of the previous window has slid out
+		needed := (state.PrevCount*overlap + state.Count + 1 - float64(s.limit)) / state.PrevCount
+		result.RetryAfter = secondsDuration(needed * s.window.Seconds())
+	} else {
+		result.RetryAfter = result.Reset
+	}
+
+	result.Remaining = int(math.Max(0, float64(s.limit)-math.Ceil(used)))
+	return result
+}
+
+func (s *slidingWindow) Window() time.Duration {
+	// The previous window's count is still needed during the next window
+	return 2 * s.window
+}
+
+func (s *slidingWindow) Policy() string {
+	return fmt.Sprintf("%d;w=%d", s.limit, ceilSeconds(s.window))
+}
+
+// KeyByIP keys requests by client IP address
+func KeyByIP() RateLimitKeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		return "ip:" + c.ClientIP(), true
+	}
+}
+
+// KeyByUserID keys requests by the authenticated user, falling back to the
+// client IP for anonymous requests. Use it after AuthMiddleware.
+func KeyByUserID() RateLimitKeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		if userID := c.GetUint("user_id"); userID != 0 {
+			return "user:" + strconv.FormatUint(uint64(userID), 10), true
+		}
+		return "ip:" + c.ClientIP(), true
+	}
+}
+
+// KeyByJSONField keys requests by a top-level field of the JSON body, such
+// as the email of a login attempt. The field name matches case-insensitively,
+// as it does when handlers bind the body, so "Email" cannot dodge the limit.
+// Requests without a usable value share a single bucket. The body is
+// restored so handlers can still bind it.
+func KeyByJSONField(field string) RateLimitKeyFunc {
+	missing := field + ":missing"
+
+	return func(c *gin.Context) (string, bool) {
+		if c.Request.Body == nil {
+			return missing, true
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxKeyBodyBytes))
+		if err != nil {
+			return missing, true
+		}
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+
+		value, ok := jsonFieldValue(body, field)
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return missing, true
+		}
+
+		return field + ":" + utils.HashToken(value), true
+	}
+}
+
+// jsonFieldValue returns the string value of a top-level field of a JSON
+// object. Like encoding/json, names match case-insensitively and the last
+// matching key wins.
+func jsonFieldValue(body []byte, field string) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return "", false
+	}
+
+	value, found := "", false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return "", false
+		}
+
+		if key, _ := token.(string); strings.EqualFold(key, field) {
+			found = json.Unmarshal(raw, &value) == nil
+		}
+	}
+
+	return value, found
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimitState is the per-key limiter state kept by a RateLimitStore
+type RateLimitState struct {
+	// Count is the available tokens (token bucket) or the number of
+	// requests in the current window (sliding window)
+	Count float64
+	// PrevCount is the number of requests in the previous window
+	PrevCount float64
+	// Timestamp is the last refill (token bucket) or the current window start
+	Timestamp time.Time
+}
+
+// RateLimitStore persists limiter state. Implementations backed by a shared
+// cache (e.g. Redis) let several API instances enforce the same limits.
+type RateLimitStore interface {
+	// Update loads the state for key, calls fn to modify it and saves it
+	// with the given TTL. The read-modify-write must be atomic per key.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// DefaultRateLimitStore is used by policies that do not set a store
+var DefaultRateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+// memoryCleanupInterval is how often expired entries are swept
+const memoryCleanupInterval = time.Minute
+
+type memoryEntry struct {
+	state     RateLimitState
+	expiresAt time.Time
+}
+
+// MemoryRateLimitStore keeps limiter state in process memory
+type MemoryRateLimitStore struct {
+	mu          sync.Mutex
+	entries     map[string]*memoryEntry
+	lastCleanup time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries:     make(map[string]*memoryEntry),
+		lastCleanup: time.Now(),
+	}
+}
+
+// Update implements RateLimitStore
+func (s *MemoryRateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastCleanup) > memoryCleanupInterval {
+		for k, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	entry, found := s.entries[key]
+	if !found || now.After(entry.expiresAt) {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	fn(&entry.state)
+	entry.expiresAt = now.Add(ttl)
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/middleware"
+)
+
+// take runs one request through the algorithm and checks the outcome
+func take(t *testing.T, algorithm middleware.RateLimitAlgorithm, state *middleware.RateLimitState, now time.Time, wantAllowed bool, wantRetryAfter time.Duration) {
+	t.Helper()
+
+	result := algorithm.Take(state, now)
+	if result.Allowed != wantAllowed {
+		t.Fatalf("at %s: got allowed %v, want %v", now.Format(time.TimeOnly), result.Allowed, wantAllowed)
+	}
+	if diff := result.RetryAfter - wantRetryAfter; diff < -time.Millisecond || diff > time.Millisecond {
+		t.Fatalf("at %s: got retry after %s, want %s", now.Format(time.TimeOnly), result.RetryAfter, wantRetryAfter)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	bucket := middleware.TokenBucket(3, 3*time.Second)
+	state := &middleware.RateLimitState{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// A full bucket allows a burst up to the limit
+	for range 3 {
+		take(t, bucket, state, start, true, 0)
+	}
+	take(t, bucket, state, start, false, time.Second)
+
+	// One token comes back per second
+	take(t, bucket, state, start.Add(500*time.Millisecond), false, 500*time.Millisecond)
+	take(t, bucket, state, start.Add(time.Second), true, 0)
+	take(t, bucket, state, start.Add(time.Second), false, time.Second)
+
+	// Refilling stops at the limit however long the bucket sits idle
+	later := start.Add(time.Hour)
+	for range 3 {
+		take(t, bucket, state, later, true, 0)
+	}
+	take(t, bucket, state, later, false, time.Second)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	window := middleware.SlidingWindow(4, time.Minute)
+	state := &middleware.RateLimitState{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for range 4 {
+		take(t, window, state, start, true, 0)
+	}
+	// Nothing from an earlier window is left to slide out, so wait for the next one
+	take(t, window, state, start.Add(10*time.Second), false, 50*time.Second)
+
+	// A quarter into the next window, three of the four earlier requests
+	// still count
+	next := start.Add(75 * time.Second)
+	take(t, window, state, next, true, 0)
+	take(t, window, state, next, false, 15*time.Second)
+	take(t, window, state, next.Add(15*time.Second), true, 0)
+
+	// Requests from two windows ago no longer count
+	for range 4 {
+		take(t, window, state, start.Add(3*time.Minute), true, 0)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+	config.AppConfig = &config.Config{RateLimitEnabled: true}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "test-login",
+		Algorithm: middleware.SlidingWindow(2, time.Hour),
+		Key:       middleware.KeyByJSONField("email"),
+		Store:     middleware.NewMemoryRateLimitStore(),
+	}), func(c *gin.Context) {
+		// The limiter must leave the body for the handler to bind
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"email": "user@example.com"}`)
+	if w.Code != http.StatusOK || w.Body.String() != `{"email": "user@example.com"}` {
+		t.Fatalf("first request: got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" ||
+		w.Header().Get("RateLimit-Policy") != "2;w=3600" {
+		t.Fatalf("unexpected rate limit headers %v", w.Header())
+	}
+
+	// Changing the case of the field or the email shares the same bucket
+	if w := post(`{"Email": "USER@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("second request: got %d", w.Code)
+	}
+	w = post(`{"email": "user@example.com"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("third request: got %d with Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if w := post(`{"email": "other@example.com"}`); w.Code != http.StatusOK {
+		t.Fatalf("another email: got %d", w.Code)
+	}
+}
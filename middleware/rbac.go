@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// RequireRole allows the request if the user has any of the given roles.
+// It must be used after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRoles := c.GetStringSlice("user_roles")
+
+		for _, role := range roles {
+			if slices.Contains(userRoles, role) {
+				c.Next()
+				return
+			}
+		}
+
+		utils.ErrorResponse(c, http.StatusForbidden, "You do not have access to this resource", "insufficient_role")
+		c.Abort()
+	}
+}
+
+// RequirePermission allows the request only if the user's roles grant all of
+// the given permissions. Service accounts hold their permissions directly.
+// It must be used after AuthMiddleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	roleService := services.NewRoleService()
+
+	return func(c *gin.Context) {
+		allowed := true
+		if _, serviceAccount := c.Get("service_account_id"); !serviceAccount {
+			var err error
+			allowed, err = roleService.HasPermissions(c.GetStringSlice("user_roles"), permissions)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check permissions", err.Error())
+				c.Abort()
+				return
+			}
+		}
+
+		if !allowed {
+			utils.ErrorResponse(c, http.StatusForbidden, "You do not have permission to perform this action", "insufficient_permission")
+			c.Abort()
+			return
+		}
+
+		if !tokenHasScopes(c, permissions) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Token does not have the required scope", "insufficient_scope")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope allows requests made with a token that carries all of the
+// given scopes. Requests from signed-in sessions are not limited by scope.
+// It must be used after AuthMiddleware.
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tokenHasScopes(c, scopes) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Token does not have the required scope", "insufficient_scope")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// tokenHasScopes reports whether the request's token, if it is limited by
+// scope, carries every one of the scopes
+func tokenHasScopes(c *gin.Context, scopes []string) bool {
+	granted, limited := c.Get("token_scopes")
+	if !limited {
+		return true
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(granted.([]string), scope) {
+			return false
+		}
+	}
+	return true
+}
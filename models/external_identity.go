@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ExternalIdentity links a user to an account at an external OAuth2/OIDC
+// provider, identified by the provider's subject
+type ExternalIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"-"`
+	Provider    string     `gorm:"type:varchar(50);uniqueIndex:idx_provider_subject;not null" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);uniqueIndex:idx_provider_subject;not null" json:"-"`
+	Email       string     `gorm:"type:varchar(255)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// OAuth 2.0 grant types supported by the authorization server
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// OAuthClient is an application registered to obtain tokens from this
+// service. Public clients have no secret and must use PKCE.
+type OAuthClient struct {
+	ID           uint     `gorm:"primaryKey" json:"id"`
+	ClientID     string   `gorm:"type:varchar(64);uniqueIndex;not null" json:"client_id"`
+	SecretHash   string   `gorm:"type:char(64)" json:"-"`
+	Name         string   `gorm:"type:varchar(100);not null" json:"name"`
+	RedirectURIs []string `gorm:"type:text;serializer:json" json:"redirect_uris"`
+	// PostLogoutRedirectURIs are where RP-initiated logout may return to
+	PostLogoutRedirectURIs []string `gorm:"type:text;serializer:json" json:"post_logout_redirect_uris"`
+	GrantTypes             []string `gorm:"type:text;serializer:json" json:"grant_types"`
+	Scopes                 []string `gorm:"type:text;serializer:json" json:"scopes"`
+	Confidential           bool     `gorm:"default:false" json:"confidential"`
+	// SkipConsent marks first-party clients users are not asked to approve
+	SkipConsent bool      `gorm:"default:false" json:"skip_consent"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AllowsGrant reports whether the client may use the grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// OAuthAuthorizationCode is a hashed, single-use authorization code
+type OAuthAuthorizationCode struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	CodeHash            string    `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ClientID            string    `gorm:"type:varchar(64);index;not null" json:"client_id"`
+	UserID              uint      `gorm:"index;not null" json:"user_id"`
+	RedirectURI         string    `gorm:"type:text" json:"redirect_uri"`
+	Scope               string    `gorm:"type:text" json:"scope"`
+	CodeChallenge       string    `gorm:"type:varchar(128)" json:"-"`
+	CodeChallengeMethod string    `gorm:"type:varchar(10)" json:"-"`
+	Nonce               string    `gorm:"type:varchar(255)" json:"-"`
+	AuthTime            time.Time `json:"auth_time"`
+	// RedirectURIIncluded records that the authorization request named the
+	// redirect URI, so the token request has to repeat it
+	RedirectURIIncluded bool `gorm:"default:false" json:"-"`
+	// GrantID ties the code to the tokens issued from it
+	GrantID   string     `gorm:"type:varchar(64);index;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OAuthRefreshToken is a hashed refresh token issued to a client. Tokens
+// rotated from one another share a GrantID.
+type OAuthRefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	TokenHash    string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ClientID     string     `gorm:"type:varchar(64);index;not null" json:"client_id"`
+	UserID       uint       `gorm:"index;not null" json:"user_id"`
+	Scope        string     `gorm:"type:text" json:"scope"`
+	GrantID      string     `gorm:"type:varchar(64);index;not null" json:"-"`
+	TokenVersion uint       `gorm:"not null;default:0" json:"-"`
+	AuthTime     time.Time  `json:"auth_time"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// OAuthAccessToken records an access token issued from a grant so it can be
+// revoked along with the grant's refresh tokens
+type OAuthAccessToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	GrantID   string    `gorm:"type:varchar(64);index;not null" json:"-"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OAuthDeviceCode is a pending device authorization. The device polls with
+// the device code while the user approves the user code in a browser.
+type OAuthDeviceCode struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	DeviceCodeHash string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	UserCodeHash   string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ClientID       string     `gorm:"type:varchar(64);index;not null" json:"client_id"`
+	Scope          string     `gorm:"type:text" json:"scope"`
+	UserID         *uint      `gorm:"index" json:"user_id"`
+	AuthTime       *time.Time `json:"auth_time"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	DeniedAt       *time.Time `json:"denied_at"`
+	// Interval is the minimum number of seconds between polls
+	Interval     int        `gorm:"not null" json:"interval"`
+	LastPolledAt *time.Time `json:"last_polled_at"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// OAuthConsent records the scopes a user has allowed a client to access
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_client;not null" json:"-"`
+	ClientID  string    `gorm:"type:varchar(64);uniqueIndex:idx_user_client;not null" json:"client_id"`
+	Scope     string    `gorm:"type:text" json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName keeps the default "o_auth_clients" naming out of the schema
+func (OAuthClient) TableName() string { return "oauth_clients" }
+
+// TableName returns the table name of authorization codes
+func (OAuthAuthorizationCode) TableName() string { return "oauth_authorization_codes" }
+
+// TableName returns the table name of client refresh tokens
+func (OAuthRefreshToken) TableName() string { return "oauth_refresh_tokens" }
+
+// TableName returns the table name of client access tokens
+func (OAuthAccessToken) TableName() string { return "oauth_access_tokens" }
+
+// TableName returns the table name of device authorizations
+func (OAuthDeviceCode) TableName() string { return "oauth_device_codes" }
+
+// TableName returns the table name of consents
+func (OAuthConsent) TableName() string { return "oauth_consents" }
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// One-time token purposes
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// OneTimeToken is a single-use token emailed to a user, either as a link
+// token or a short numeric code. Only the SHA-256 hash is stored.
+type OneTimeToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index;not null" json:"-"`
+	Purpose   string `gorm:"type:varchar(50);index;not null" json:"purpose"`
+	TokenHash string `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	// IsCode marks short codes, which are only accepted together with the
+	// user's email and a limited number of attempts
+	IsCode    bool       `gorm:"default:false" json:"is_code"`
+	Attempts  int        `gorm:"default:0" json:"-"`
+	ExpiresAt time.Time  `gorm:"index;not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
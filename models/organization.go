@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Organization roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// OrgRoles lists the roles a member can hold in an organization
+var OrgRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember}
+
+// Organization is a customer company whose members share its data
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Slug      string    `gorm:"type:varchar(63);uniqueIndex;not null" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrganizationMembership gives a user a role in an organization
+type OrganizationMembership struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	OrganizationID uint          `gorm:"uniqueIndex:idx_organization_member;not null" json:"organization_id"`
+	UserID         uint          `gorm:"uniqueIndex:idx_organization_member;index;not null" json:"user_id"`
+	Role           string        `gorm:"type:varchar(20);not null" json:"role"`
+	Organization   *Organization `json:"organization,omitempty"`
+	User           *User         `json:"user,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// OrganizationInvitation offers a role in an organization to an email
+// address until the invitee accepts it. Only the SHA-256 hash of the emailed
+// token is stored.
+type OrganizationInvitation struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"uniqueIndex:idx_organization_invitation;not null" json:"organization_id"`
+	Email          string    `gorm:"type:varchar(255);uniqueIndex:idx_organization_invitation;not null" json:"email"`
+	Role           string    `gorm:"type:varchar(20);not null" json:"role"`
+	TokenHash      string    `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	InvitedByID    uint      `gorm:"not null" json:"invited_by_id"`
+	ExpiresAt      time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// IsExpired reports whether the invitation can no longer be accepted
+func (i *OrganizationInvitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// IsValidOrgRole reports whether the role exists
+func IsValidOrgRole(role string) bool {
+	return slices.Contains(OrgRoles, role)
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Scopes a personal access token can carry besides the permissions granted
+// by its owner's roles
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+// PersonalAccessToken is a long-lived credential a user creates for scripts
+// and other automation. Only a hash of the secret is stored.
+type PersonalAccessToken struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	UserID    uint   `gorm:"index;not null" json:"-"`
+	Name      string `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash string `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	// Prefix is the start of the secret, shown to tell tokens apart
+	Prefix     string     `gorm:"type:varchar(16)" json:"prefix"`
+	Scopes     []string   `gorm:"type:text;serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"type:varchar(45)" json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// TokenVersion must match the user's token version, so the token stops
+	// working when the user's tokens are invalidated
+	TokenVersion uint `gorm:"not null;default:0" json:"-"`
+}
+
+// IsExpired reports whether the token has passed its expiry, if it has one
+func (t *PersonalAccessToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
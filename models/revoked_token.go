@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RevokedToken records an access token (by its jti) that must no longer be
+// accepted. Rows are only needed until the token would have expired anyway.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JTI       string    `gorm:"column:jti;type:varchar(64);uniqueIndex;not null" json:"jti"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
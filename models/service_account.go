@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// ServiceAccount is a principal for machine-to-machine callers. It cannot
+// sign in; it authenticates with API keys and holds permissions directly
+// rather than through roles.
+type ServiceAccount struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+	// OrganizationID is set when the account belongs to an organization
+	OrganizationID *uint `gorm:"index" json:"organization_id"`
+	// OwnerID is the admin responsible for the account, whose roles must
+	// keep granting its permissions
+	OwnerID     uint      `gorm:"index;not null" json:"owner_id"`
+	Permissions []string  `gorm:"type:text;serializer:json" json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey is a hashed secret a service account authenticates with. An account
+// can hold several keys so they can be rotated without downtime.
+type APIKey struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	ServiceAccountID uint   `gorm:"index;not null" json:"service_account_id"`
+	KeyHash          string `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	// Prefix is the start of the key, shown to tell keys apart
+	Prefix     string     `gorm:"type:varchar(16)" json:"prefix"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"type:varchar(45)" json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsExpired reports whether the key has passed its expiry, if it has one
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Session represents a signed-in device. Access and refresh tokens carry the
+// session ID so revoking the session signs that device out.
+type Session struct {
+	ID         string     `gorm:"type:varchar(64);primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	IPAddress  string     `gorm:"type:varchar(45)" json:"ip_address"`
+	UserAgent  string     `gorm:"type:varchar(512)" json:"user_agent"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// OrganizationID is the organization the session is working in, if any
+	OrganizationID *uint `gorm:"index" json:"organization_id"`
+}
+
+// IsActive reports whether the session has not been revoked or expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -3,47 +3,75 @@ package models
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID                uint           `gorm:"primaryKey" json:"id"`
-	Email             string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
-	Password          string         `gorm:"type:varchar(255);not null" json:"-"`
-	Name              string         `gorm:"type:varchar(255);not null" json:"name"`
-	IsEmailVerified   bool           `gorm:"default:false" json:"is_email_verified"`
-	VerificationToken string         `gorm:"type:varchar(255)" json:"-"`
-	ResetToken        string         `gorm:"type:varchar(255)" json:"-"`
-	ResetTokenExpiry  *time.Time     `json:"-"`
-	CreatedAt         time.Time      `json:"created_at"`
-	UpdatedAt         time.Time      `json:"updated_at"`
-	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	Email                 string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
+	Password              string         `gorm:"type:varchar(255);not null" json:"-"`
+	Name                  string         `gorm:"type:varchar(255);not null" json:"name"`
+	IsEmailVerified       bool           `gorm:"default:false" json:"is_email_verified"`
+	PasswordResetRequired bool           `gorm:"default:false" json:"password_reset_required"`
+	TOTPSecret            string         `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
+	TOTPEnabled           bool           `gorm:"column:totp_enabled;default:false" json:"totp_enabled"`
+	TOTPLastUsedStep      int64          `gorm:"column:totp_last_used_step;default:0" json:"-"`
+	DisabledAt            *time.Time     `json:"disabled_at"`
+	FailedLoginAttempts   int            `gorm:"default:0" json:"-"`
+	LastFailedLoginAt     *time.Time     `json:"-"`
+	LockedUntil           *time.Time     `json:"locked_until"`
+	TokenVersion          uint           `gorm:"default:0" json:"-"`
+	Roles                 []Role         `gorm:"many2many:user_roles;" json:"roles,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // BeforeCreate hook to hash password before saving
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+		hashedPassword, err := utils.HashPassword(u.Password)
 		if err != nil {
 			return err
 		}
-		u.Password = string(hashedPassword)
+		u.Password = hashedPassword
 	}
 	return nil
 }
 
 // CheckPassword compares password with hashed password
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	return err == nil
+	ok, _ := u.VerifyPassword(password)
+	return ok
 }
 
-// HashPassword hashes a password
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+// VerifyPassword compares password with hashed password and also reports
+// whether the stored hash should be upgraded to the configured algorithm
+func (u *User) VerifyPassword(password string) (ok bool, needsRehash bool) {
+	return utils.VerifyPassword(password, u.Password)
+}
+
+// IsDisabled reports whether an admin has disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// IsLocked reports whether the account is temporarily locked after too many failed logins
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
+}
+
+// RoleNames returns the names of the user's loaded roles
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
 	}
-	return string(hashedPassword), nil
+	return names
+}
+
+// HashPassword hashes a password with the configured password hasher
+func HashPassword(password string) (string, error) {
+	return utils.HashPassword(password)
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey registered by a user. It can be used for
+// passwordless login or as a second factor.
+type WebAuthnCredential struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"-"`
+	Name   string `gorm:"type:varchar(100);not null" json:"name"`
+	// CredentialIDHash is the SHA-256 hash of the raw credential ID, used for
+	// lookups since credential IDs can be too long to index
+	CredentialIDHash string `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	// CredentialID is the base64url-encoded credential ID
+	CredentialID string `gorm:"type:text;not null" json:"-"`
+	// PublicKey is the COSE-encoded credential public key
+	PublicKey []byte `gorm:"type:blob;not null" json:"-"`
+	SignCount uint32 `gorm:"default:0" json:"-"`
+	// UserHandle is the base64url-encoded WebAuthn user ID shared by all of a
+	// user's credentials
+	UserHandle     string     `gorm:"type:varchar(64);index;not null" json:"-"`
+	AAGUID         string     `gorm:"type:char(36)" json:"aaguid"`
+	Transports     string     `gorm:"type:varchar(255)" json:"-"`
+	BackupEligible bool       `gorm:"default:false" json:"backup_eligible"`
+	BackedUp       bool       `gorm:"default:false" json:"backed_up"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -1,14 +1,79 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/controllers"
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/middleware"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/services"
 )
 
 // SetupRoutes configures all application routes
 func SetupRoutes(router *gin.Engine) {
 	authController := controllers.NewAuthController()
+	sessionController := controllers.NewSessionController()
+	adminUserController := controllers.NewAdminUserController()
+	mfaController := controllers.NewMFAController()
+	passkeyController := controllers.NewPasskeyController()
+	identityController := controllers.NewIdentityController()
+	wellKnownController := controllers.NewWellKnownController()
+	oauthController := controllers.NewOAuthController()
+	oauthClientController := controllers.NewOAuthClientController()
+	patController := controllers.NewPersonalAccessTokenController()
+	serviceAccountController := controllers.NewServiceAccountController()
+	organizationController := controllers.NewOrganizationController()
+
+	// Rate limit policies for public endpoints
+	perIPBurst := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "auth-ip",
+		Algorithm: middleware.TokenBucket(20, time.Minute),
+		Key:       middleware.KeyByIP(),
+	})
+	registerLimit := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "register-ip",
+		Algorithm: middleware.SlidingWindow(5, time.Hour),
+		Key:       middleware.KeyByIP(),
+	})
+	loginEmailLimit := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "login-email",
+		Algorithm: middleware.SlidingWindow(10, 15*time.Minute),
+		Key:       middleware.KeyByJSONField("email"),
+	})
+	emailSendLimit := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "email-send",
+		Algorithm: middleware.SlidingWindow(3, time.Hour),
+		Key:       middleware.KeyByJSONField("email"),
+	})
+	emailSendIPLimit := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "email-send-ip",
+		Algorithm: middleware.SlidingWindow(10, time.Hour),
+		Key:       middleware.KeyByIP(),
+	})
+	userCodeLimit := middleware.RateLimit(middleware.RateLimitPolicy{
+		Name:      "device-user-code",
+		Algorithm: middleware.SlidingWindow(10, 15*time.Minute),
+		Key:       middleware.KeyByUserID(),
+	})
+
+	// Public discovery documents
+	router.GET("/.well-known/jwks.json", wellKnownController.JWKS)
+	router.GET("/.well-known/openid-configuration", wellKnownController.OpenIDConfiguration)
+
+	// OAuth 2.0 authorization server endpoints used by client apps
+	oauth := router.Group("/oauth")
+	{
+		oauth.GET("/authorize", oauthController.Authorize)
+		oauth.POST("/token", perIPBurst, oauthController.Token)
+		oauth.POST("/device_authorization", perIPBurst, oauthController.DeviceAuthorization)
+		oauth.POST("/introspect", oauthController.Introspect)
+		oauth.POST("/revoke", perIPBurst, oauthController.Revoke)
+		oauth.GET("/userinfo", middleware.OAuthTokenMiddleware(services.ScopeOpenID), oauthController.UserInfo)
+		oauth.POST("/userinfo", middleware.OAuthTokenMiddleware(services.ScopeOpenID), oauthController.UserInfo)
+		oauth.GET("/logout", oauthController.EndSession)
+		oauth.POST("/logout", oauthController.EndSession)
+	}
 
 	// API v1 group
 	v1 := router.Group("/api/v1")
@@ -23,25 +88,164 @@ func SetupRoutes(router *gin.Engine) {
 
 		// Auth routes (public)
 		auth := v1.Group("/auth")
+		auth.Use(perIPBurst)
 		{
-			auth.POST("/register", authController.Register)
-			auth.POST("/login", authController.Login)
-			auth.POST("/forgot-password", authController.ForgotPassword)
+			auth.POST("/register", registerLimit, authController.Register)
+			auth.POST("/login", loginEmailLimit, authController.Login)
+			auth.POST("/mfa/verify", authController.VerifyMFA)
+			auth.POST("/mfa/passkey/options", authController.MFAPasskeyOptions)
+			auth.POST("/passkey/login/begin", authController.BeginPasskeyLogin)
+			auth.POST("/passkey/login/finish", authController.FinishPasskeyLogin)
+			auth.GET("/oauth/providers", authController.ListIdentityProviders)
+			auth.POST("/oauth/:provider/authorize", authController.BeginOAuthLogin)
+			auth.POST("/oauth/:provider/callback", authController.OAuthCallback)
+			auth.POST("/magic-link", emailSendIPLimit, emailSendLimit, authController.RequestMagicLink)
+			auth.GET("/magic-link", authController.CheckMagicLink)
+			auth.POST("/magic-link/confirm", authController.ConfirmMagicLink)
+			auth.POST("/refresh", authController.RefreshToken)
+			auth.POST("/forgot-password", emailSendIPLimit, emailSendLimit, authController.ForgotPassword)
 			auth.POST("/reset-password", authController.ResetPassword)
 			auth.GET("/verify-email", authController.VerifyEmail)
+			auth.POST("/verify-email", authController.VerifyEmailCode)
 		}
 
 		// Protected routes (require authentication)
 		protected := v1.Group("/")
 		protected.Use(middleware.AuthMiddleware())
 		{
-			// User routes
+			sessionOnly := middleware.RequireSession()
+
+			// Profile routes, also open to personal access tokens with a profile scope
 			user := protected.Group("/user")
 			{
-				user.GET("/profile", authController.GetProfile)
-				user.PUT("/profile", authController.UpdateProfile)
-				user.POST("/change-password", authController.ChangePassword)
-				user.POST("/logout", authController.Logout)
+				user.GET("/profile", middleware.RequireScope(models.ScopeProfileRead), authController.GetProfile)
+				user.PUT("/profile", middleware.RequireScope(models.ScopeProfileWrite), authController.UpdateProfile)
+			}
+
+			// Account routes, which need a signed-in session
+			account := protected.Group("/user", sessionOnly)
+			{
+				account.POST("/change-password", authController.ChangePassword)
+				account.POST("/logout", authController.Logout)
+
+				// Session management
+				account.GET("/sessions", sessionController.ListSessions)
+				account.DELETE("/sessions", sessionController.RevokeOtherSessions)
+				account.DELETE("/sessions/:id", sessionController.RevokeSession)
+
+				// Two-factor authentication
+				account.GET("/mfa", mfaController.GetMFAStatus)
+				account.POST("/mfa/totp/setup", mfaController.SetupTOTP)
+				account.POST("/mfa/totp/confirm", mfaController.ConfirmTOTP)
+				account.POST("/mfa/totp/disable", mfaController.DisableTOTP)
+				account.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+
+				// Passkeys
+				account.GET("/passkeys", passkeyController.ListPasskeys)
+				account.POST("/passkeys/register/begin", passkeyController.BeginRegistration)
+				account.POST("/passkeys/register/finish", passkeyController.FinishRegistration)
+				account.PATCH("/passkeys/:id", passkeyController.RenamePasskey)
+				account.DELETE("/passkeys/:id", passkeyController.DeletePasskey)
+
+				// Linked external identities
+				account.GET("/identities", identityController.ListIdentities)
+				account.POST("/identities/:provider/authorize", identityController.BeginLink)
+				account.POST("/identities/:provider/link", identityController.LinkIdentity)
+				account.DELETE("/identities/:id", identityController.UnlinkIdentity)
+
+				// Apps authorized through OAuth
+				account.GET("/consents", oauthController.ListConsents)
+				account.DELETE("/consents/:client_id", oauthController.RevokeConsent)
+
+				// Personal access tokens
+				account.GET("/tokens", patController.ListTokens)
+				account.POST("/tokens", patController.CreateToken)
+				account.DELETE("/tokens/:id", patController.DeleteToken)
+
+				// Organizations the user belongs to
+				account.GET("/organizations", organizationController.ListOrganizations)
+				account.POST("/organizations", organizationController.CreateOrganization)
+				account.POST("/organizations/switch", organizationController.SwitchOrganization)
+				account.POST("/organizations/invitations/accept", organizationController.AcceptInvitation)
+			}
+
+			// Active organization, taken from the access token
+			organization := protected.Group("/organization", middleware.RequireOrganization())
+			{
+				canManage := middleware.RequireOrganizationRole(models.OrgRoleOwner, models.OrgRoleAdmin)
+				ownerOnly := middleware.RequireOrganizationRole(models.OrgRoleOwner)
+
+				organization.GET("", organizationController.GetOrganization)
+				organization.PUT("", canManage, organizationController.UpdateOrganization)
+				organization.DELETE("", ownerOnly, organizationController.DeleteOrganization)
+				organization.GET("/members", organizationController.ListMembers)
+				organization.PUT("/members/:user_id", canManage, organizationController.UpdateMember)
+				organization.DELETE("/members/:user_id", organizationController.RemoveMember)
+				organization.GET("/invitations", canManage, organizationController.ListInvitations)
+				organization.POST("/invitations", canManage, emailSendLimit, organizationController.InviteMember)
+				organization.DELETE("/invitations/:id", canManage, organizationController.RevokeInvitation)
+			}
+
+			// OAuth consent page
+			protected.GET("/oauth/authorize", sessionOnly, oauthController.GetAuthorization)
+			protected.POST("/oauth/authorize", sessionOnly, oauthController.ApproveAuthorization)
+			protected.POST("/oauth/logout", sessionOnly, oauthController.ConfirmLogout)
+
+			// OAuth device flow verification page
+			protected.GET("/oauth/device", sessionOnly, userCodeLimit, oauthController.GetDeviceAuthorization)
+			protected.POST("/oauth/device", sessionOnly, userCodeLimit, oauthController.DecideDeviceAuthorization)
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			{
+				users := admin.Group("/users")
+				{
+					canRead := middleware.RequirePermission(models.PermissionUsersRead)
+					canWrite := middleware.RequirePermission(models.PermissionUsersWrite)
+
+					users.GET("", canRead, adminUserController.ListUsers)
+					users.GET("/:id", canRead, adminUserController.GetUser)
+					users.POST("", canWrite, adminUserController.CreateUser)
+					users.PUT("/:id", canWrite, adminUserController.UpdateUser)
+					users.DELETE("/:id", canWrite, adminUserController.DeleteUser)
+					users.POST("/:id/restore", canWrite, adminUserController.RestoreUser)
+					users.POST("/:id/disable", canWrite, adminUserController.DisableUser)
+					users.POST("/:id/enable", canWrite, adminUserController.EnableUser)
+					users.POST("/:id/unlock", canWrite, adminUserController.UnlockUser)
+					users.POST("/:id/force-password-reset", canWrite, adminUserController.ForcePasswordReset)
+					users.POST("/:id/verify-email", canWrite, adminUserController.VerifyUserEmail)
+				}
+
+				clients := admin.Group("/oauth/clients")
+				{
+					canRead := middleware.RequirePermission(models.PermissionOAuthClientsRead)
+					canWrite := middleware.RequirePermission(models.PermissionOAuthClientsWrite)
+
+					clients.GET("", canRead, oauthClientController.ListClients)
+					clients.GET("/:client_id", canRead, oauthClientController.GetClient)
+					clients.POST("", canWrite, oauthClientController.CreateClient)
+					clients.PUT("/:client_id", canWrite, oauthClientController.UpdateClient)
+					clients.DELETE("/:client_id", canWrite, oauthClientController.DeleteClient)
+					clients.POST("/:client_id/rotate-secret", canWrite, oauthClientController.RotateClientSecret)
+				}
+
+				// Managing service accounts needs a signed-in admin, so API
+				// keys cannot mint further keys
+				serviceAccounts := admin.Group("/service-accounts")
+				{
+					canRead := middleware.RequirePermission(models.PermissionServiceAccountsRead)
+					canWrite := middleware.RequirePermission(models.PermissionServiceAccountsWrite)
+
+					serviceAccounts.GET("", canRead, serviceAccountController.ListServiceAccounts)
+					serviceAccounts.GET("/:id", canRead, serviceAccountController.GetServiceAccount)
+					serviceAccounts.POST("", sessionOnly, canWrite, serviceAccountController.CreateServiceAccount)
+					serviceAccounts.PUT("/:id", sessionOnly, canWrite, serviceAccountController.UpdateServiceAccount)
+					serviceAccounts.DELETE("/:id", sessionOnly, canWrite, serviceAccountController.DeleteServiceAccount)
+					serviceAccounts.GET("/:id/keys", canRead, serviceAccountController.ListAPIKeys)
+					serviceAccounts.POST("/:id/keys", sessionOnly, canWrite, serviceAccountController.CreateAPIKey)
+					serviceAccounts.POST("/:id/keys/:key_id/rotate", sessionOnly, canWrite, serviceAccountController.RotateAPIKey)
+					serviceAccounts.DELETE("/:id/keys/:key_id", sessionOnly, canWrite, serviceAccountController.DeleteAPIKey)
+				}
 			}
 		}
 	}
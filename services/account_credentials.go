@@ -0,0 +1,38 @@
+package services
+
+import (
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+// ResetAccountCredentials removes every way of signing in to the account the
+// user set up: their password, TOTP and recovery codes, passkeys, personal
+// access tokens and linked external identities. It is used when the verified
+// owner of an email claims an account someone else registered with it.
+func ResetAccountCredentials(tx *gorm.DB, user *models.User) error {
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password":            "",
+		"totp_enabled":        false,
+		"totp_secret":         "",
+		"totp_last_used_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	user.Password = ""
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastUsedStep = 0
+
+	for _, credential := range []interface{}{
+		&models.RecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.PersonalAccessToken{},
+		&models.ExternalIdentity{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(credential).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"log"
+	"time"
+)
+
+// StartCleanup periodically purges expired token revocations, one-time
+// tokens, ended sessions with their refresh tokens, OAuth codes and tokens and
+// organization invitations so those tables do not grow forever
+func StartCleanup(interval time.Duration) {
+	oneTimeTokenService := NewOneTimeTokenService()
+	sessionService := NewSessionService()
+	oauthServerService := NewOAuthServerService()
+	organizationService := NewOrganizationService()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := Revocations.Purge(); err != nil {
+				log.Printf("Failed to purge revoked tokens: %v", err)
+			}
+			if err := oneTimeTokenService.Purge(); err != nil {
+				log.Printf("Failed to purge one-time tokens: %v", err)
+			}
+			if err := sessionService.Purge(); err != nil {
+				log.Printf("Failed to purge sessions and refresh tokens: %v", err)
+			}
+			if err := oauthServerService.Purge(); err != nil {
+				log.Printf("Failed to purge OAuth codes and tokens: %v", err)
+			}
+			if err := organizationService.PurgeInvitations(); err != nil {
+				log.Printf("Failed to purge organization invitations: %v", err)
+			}
+		}
+	}()
+}
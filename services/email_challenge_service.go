@@ -0,0 +1,141 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+// Delivery modes for emailed one-time secrets
+const (
+	DeliveryModeLink = "link"
+	DeliveryModeCode = "code"
+)
+
+// EmailChallenge is a freshly issued link token or code waiting to be emailed
+type EmailChallenge struct {
+	Email   string
+	Purpose string
+	Mode    string
+	Secret  string
+}
+
+type EmailChallengeService struct {
+	tokenService *OneTimeTokenService
+	emailService *EmailService
+}
+
+// NewEmailChallengeService creates a new email challenge service
+func NewEmailChallengeService() *EmailChallengeService {
+	return &EmailChallengeService{
+		tokenService: NewOneTimeTokenService(),
+		emailService: NewEmailService(),
+	}
+}
+
+// DeliveryMode returns whether the flow for the purpose emails a link or a code
+func DeliveryMode(purpose string) string {
+	cfg := config.AppConfig
+
+	var mode string
+	switch purpose {
+	case models.TokenPurposeEmailVerification:
+		mode = cfg.EmailVerificationMode
+	case models.TokenPurposePasswordReset:
+		mode = cfg.PasswordResetMode
+	case models.TokenPurposeMagicLink:
+		mode = cfg.PasswordlessLoginMode
+	}
+
+	if strings.ToLower(mode) == DeliveryModeCode {
+		return DeliveryModeCode
+	}
+	return DeliveryModeLink
+}
+
+// Issue creates a link token or code for the purpose using the configured
+// delivery mode. Send it with Send once the surrounding transaction commits.
+func (s *EmailChallengeService) Issue(db *gorm.DB, user *models.User, purpose string) (*EmailChallenge, error) {
+	challenge := &EmailChallenge{
+		Email:   user.Email,
+		Purpose: purpose,
+		Mode:    DeliveryMode(purpose),
+	}
+
+	var err error
+	if challenge.Mode == DeliveryModeCode {
+		challenge.Secret, err = s.tokenService.IssueCode(db, user.ID, purpose, emailCodeTTL())
+	} else {
+		challenge.Secret, err = s.tokenService.Issue(db, user.ID, purpose, linkTTL(purpose))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// Send emails the challenge in the background
+func (s *EmailChallengeService) Send(challenge *EmailChallenge) {
+	go func() {
+		var err error
+		switch {
+		case challenge.Mode == DeliveryModeCode:
+			err = s.emailService.SendCodeEmail(challenge.Email, challenge.Purpose, challenge.Secret)
+		case challenge.Purpose == models.TokenPurposeEmailVerification:
+			err = s.emailService.SendVerificationEmail(challenge.Email, challenge.Secret)
+		case challenge.Purpose == models.TokenPurposePasswordReset:
+			err = s.emailService.SendPasswordResetEmail(challenge.Email, challenge.Secret)
+		case challenge.Purpose == models.TokenPurposeMagicLink:
+			err = s.emailService.SendMagicLinkEmail(challenge.Email, challenge.Secret)
+		}
+		if err != nil {
+			log.Printf("Failed to send %s email: %v", challenge.Purpose, err)
+		}
+	}()
+}
+
+// Consume accepts either a link token or the user's email together with a
+// code, and marks it as used
+func (s *EmailChallengeService) Consume(db *gorm.DB, purpose, token, email, code string) (*models.OneTimeToken, error) {
+	if token != "" {
+		return s.tokenService.Consume(db, token, purpose)
+	}
+	if email == "" || code == "" {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	var user models.User
+	result := db.Where("email = ?", email).Limit(1).Find(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	return s.tokenService.ConsumeCode(db, user.ID, purpose, strings.TrimSpace(code))
+}
+
+// emailCodeTTL returns how long emailed codes stay valid
+func emailCodeTTL() time.Duration {
+	return time.Duration(config.AppConfig.EmailCodeMinutes) * time.Minute
+}
+
+// linkTTL returns how long emailed links for the purpose stay valid
+func linkTTL(purpose string) time.Duration {
+	cfg := config.AppConfig
+
+	switch purpose {
+	case models.TokenPurposeEmailVerification:
+		return time.Duration(cfg.EmailVerificationTokenHours) * time.Hour
+	case models.TokenPurposeMagicLink:
+		return time.Duration(cfg.MagicLinkMinutes) * time.Minute
+	default:
+		return time.Duration(cfg.PasswordResetTokenMinutes) * time.Minute
+	}
+}
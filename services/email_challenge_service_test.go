@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+// issueTestCode emails the user a password reset code
+func issueTestCode(t *testing.T, user *models.User) string {
+	t.Helper()
+
+	config.AppConfig.PasswordResetMode = DeliveryModeCode
+	challenge, err := NewEmailChallengeService().Issue(database.DB, user, models.TokenPurposePasswordReset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Mode != DeliveryModeCode || !regexp.MustCompile(`^\d{6}$`).MatchString(challenge.Secret) {
+		t.Fatalf("got a %s challenge with secret %q, want a six digit code", challenge.Mode, challenge.Secret)
+	}
+	return challenge.Secret
+}
+
+func TestEmailCodeIsStoredHashedAndUsableOnce(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	user := createTestUser(t, "code@example.com")
+	other := createTestUser(t, "other@example.com")
+	service := NewEmailChallengeService()
+	code := issueTestCode(t, user)
+
+	var stored models.OneTimeToken
+	if err := database.DB.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsCode || stored.TokenHash == code {
+		t.Fatalf("stored %+v, want a hashed code", stored)
+	}
+
+	// A code only works for the account and flow it was sent for
+	if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", other.Email, code); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming with another email: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+	if _, err := service.Consume(database.DB, models.TokenPurposeEmailVerification, "", user.Email, code); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming for another purpose: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+	if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, code, "", ""); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming the code as a link token: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+
+	token, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, " "+code+" ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserID != user.ID {
+		t.Fatalf("consumed a code for user %d, want %d", token.UserID, user.ID)
+	}
+
+	if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, code); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming twice: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+}
+
+func TestEmailCodeAttemptsExceeded(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	config.AppConfig.EmailCodeMaxAttempts = 3
+	user := createTestUser(t, "code@example.com")
+	service := NewEmailChallengeService()
+	code := issueTestCode(t, user)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		want := ErrInvalidOneTimeToken
+		if attempt == 3 {
+			want = ErrCodeAttemptsExceeded
+		}
+		if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, wrong); !errors.Is(err, want) {
+			t.Fatalf("wrong guess %d: got %v, want %v", attempt, err, want)
+		}
+	}
+
+	// Once the guesses run out even the right code is refused
+	if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, code); !errors.Is(err, ErrCodeAttemptsExceeded) {
+		t.Fatalf("right code after too many guesses: got %v, want %v", err, ErrCodeAttemptsExceeded)
+	}
+
+	// A new code starts a fresh count
+	code = issueTestCode(t, user)
+	if _, err := service.Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, code); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEmailCodeExpiry(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	user := createTestUser(t, "code@example.com")
+	code := issueTestCode(t, user)
+
+	if err := database.DB.Model(&models.OneTimeToken{}).Where("user_id = ?", user.ID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEmailChallengeService().Consume(database.DB, models.TokenPurposePasswordReset, "", user.Email, code); !errors.Is(err, ErrOneTimeTokenExpired) {
+		t.Fatalf("consuming an expired code: got %v, want %v", err, ErrOneTimeTokenExpired)
+	}
+}
@@ -2,9 +2,12 @@ package services
 
 import (
 	"fmt"
+	"html"
 	"net/smtp"
+	"time"
 
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
 )
 
 type EmailService struct{}
@@ -68,11 +71,105 @@ func (s *EmailService) SendPasswordResetEmail(to, token string) error {
 			<h2>Password Reset Request</h2>
 			<p>You have requested to reset your password. Click the link below to reset your password:</p>
 			<p><a href="%s">Reset Password</a></p>
-			<p>This link will expire in 1 hour.</p>
+			<p>This link will expire in %d minutes.</p>
 			<p>If you did not request this, please ignore this email.</p>
 		</body>
 		</html>
-	`, resetLink)
+	`, resetLink, cfg.PasswordResetTokenMinutes)
+
+	return s.SendEmail(to, subject, body)
+}
+
+// SendMagicLinkEmail sends a passwordless sign-in link
+func (s *EmailService) SendMagicLinkEmail(to, token string) error {
+	cfg := config.AppConfig
+	signInLink := fmt.Sprintf("%s/magic-link?token=%s", cfg.FrontendURL, token)
+
+	subject := "Your Sign-In Link"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Sign In</h2>
+			<p>Click the link below to sign in to your account:</p>
+			<p><a href="%s">Sign In</a></p>
+			<p>This link will expire in %d minutes and can only be used once.</p>
+			<p>If you did not request this, please ignore this email.</p>
+		</body>
+		</html>
+	`, signInLink, cfg.MagicLinkMinutes)
+
+	return s.SendEmail(to, subject, body)
+}
+
+// SendCodeEmail sends a numeric one-time code for the given token purpose
+func (s *EmailService) SendCodeEmail(to, purpose, code string) error {
+	cfg := config.AppConfig
+
+	var subject, intro string
+	switch purpose {
+	case models.TokenPurposeEmailVerification:
+		subject = "Your Verification Code"
+		intro = "Thank you for registering. Enter this code to verify your email address:"
+	case models.TokenPurposePasswordReset:
+		subject = "Your Password Reset Code"
+		intro = "You have requested to reset your password. Enter this code to choose a new password:"
+	default:
+		subject = "Your Sign-In Code"
+		intro = "Enter this code to sign in to your account:"
+	}
+
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>%s</h2>
+			<p>%s</p>
+			<p style="font-size: 24px; letter-spacing: 4px;"><strong>%s</strong></p>
+			<p>This code will expire in %d minutes.</p>
+			<p>If you did not request this, please ignore this email.</p>
+		</body>
+		</html>
+	`, subject, intro, code, cfg.EmailCodeMinutes)
+
+	return s.SendEmail(to, subject, body)
+}
+
+// SendOrganizationInvitationEmail invites someone to join an organization
+func (s *EmailService) SendOrganizationInvitationEmail(to, organizationName, role, token string) error {
+	cfg := config.AppConfig
+	acceptLink := fmt.Sprintf("%s/organizations/invitations/accept?token=%s", cfg.FrontendURL, token)
+
+	subject := "You Have Been Invited to an Organization"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Organization Invitation</h2>
+			<p>You have been invited to join <strong>%s</strong> as %s. Sign in or create an account with this email address, then click the link below to accept:</p>
+			<p><a href="%s">Accept Invitation</a></p>
+			<p>This link will expire in %d days.</p>
+			<p>If you were not expecting this, please ignore this email.</p>
+		</body>
+		</html>
+	`, html.EscapeString(organizationName), role, acceptLink, cfg.OrgInvitationDays)
+
+	return s.SendEmail(to, subject, body)
+}
+
+// SendAccountLockedEmail notifies a user that their account was locked after failed logins
+func (s *EmailService) SendAccountLockedEmail(to string, lockedUntil time.Time) error {
+	cfg := config.AppConfig
+	resetLink := fmt.Sprintf("%s/forgot-password", cfg.FrontendURL)
+
+	subject := "Your Account Has Been Locked"
+	body := fmt.Sprintf(`
+		<html>
+		<body>
+			<h2>Account Temporarily Locked</h2>
+			<p>We detected several failed sign-in attempts on your account, so we have locked it until %s.</p>
+			<p>If this was you, you can try again after that time.</p>
+			<p>If this was not you, we recommend <a href="%s">resetting your password</a>.</p>
+		</body>
+		</html>
+	`, lockedUntil.Format("2006-01-02 15:04 MST"), resetLink)
 
 	return s.SendEmail(to, subject, body)
 }
@@ -89,10 +186,11 @@ func (s *EmailService) SendVerificationEmail(to, token string) error {
 			<h2>Welcome!</h2>
 			<p>Thank you for registering. Please verify your email address by clicking the link below:</p>
 			<p><a href="%s">Verify Email</a></p>
+			<p>This link will expire in %d hours.</p>
 			<p>If you did not register, please ignore this email.</p>
 		</body>
 		</html>
-	`, verificationLink)
+	`, verificationLink, cfg.EmailVerificationTokenHours)
 
 	return s.SendEmail(to, subject, body)
 }
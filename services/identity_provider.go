@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+var (
+	ErrIdentityProviderNotFound = errors.New("identity_provider_not_found")
+	ErrIdentityProviderFailed   = errors.New("identity_provider_error")
+	ErrInvalidIDToken           = errors.New("invalid_id_token")
+)
+
+// maxProviderResponseBytes bounds responses read from identity providers
+const maxProviderResponseBytes = 1 << 20
+
+// idTokenLeeway tolerates clock skew between us and the provider
+const idTokenLeeway = time.Minute
+
+// ExternalProfile is the identity an external provider asserted for a user
+type ExternalProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// providerMetadata is the subset of an OIDC discovery document we use
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// providerTokenResponse is the token endpoint response of a provider
+type providerTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// IdentityProvider signs users in with an external OAuth2/OIDC provider.
+// Providers with an issuer are treated as OpenID Connect providers and must
+// return an ID token; others are plain OAuth2 and identify the user through
+// their userinfo endpoint.
+type IdentityProvider struct {
+	config config.IdentityProviderConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *providerMetadata
+	keys     map[string]interface{}
+}
+
+var (
+	identityProvidersMu sync.RWMutex
+	identityProviders   = map[string]*IdentityProvider{}
+)
+
+// NewIdentityProvider creates a provider from its configuration
+func NewIdentityProvider(cfg config.IdentityProviderConfig) *IdentityProvider {
+	return &IdentityProvider{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LoadIdentityProviders registers the providers configured in the environment
+func LoadIdentityProviders() error {
+	for _, cfg := range config.AppConfig.IdentityProviders {
+		if cfg.ClientID == "" {
+			return fmt.Errorf("identity provider %q has no client ID", cfg.Name)
+		}
+		if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "") {
+			return fmt.Errorf("identity provider %q needs an issuer or auth, token and userinfo URLs", cfg.Name)
+		}
+		RegisterIdentityProvider(NewIdentityProvider(cfg))
+	}
+	return nil
+}
+
+// RegisterIdentityProvider makes a provider available for sign-in, replacing
+// any provider with the same name
+func RegisterIdentityProvider(provider *IdentityProvider) {
+	identityProvidersMu.Lock()
+	defer identityProvidersMu.Unlock()
+	identityProviders[provider.Name()] = provider
+}
+
+// GetIdentityProvider returns the registered provider with the given name
+func GetIdentityProvider(name string) (*IdentityProvider, error) {
+	identityProvidersMu.RLock()
+	defer identityProvidersMu.RUnlock()
+
+	provider, found := identityProviders[strings.ToLower(name)]
+	if !found {
+		return nil, ErrIdentityProviderNotFound
+	}
+	return provider, nil
+}
+
+// IdentityProviderNames lists the registered providers
+func IdentityProviderNames() []string {
+	identityProvidersMu.RLock()
+	defer identityProvidersMu.RUnlock()
+
+	names := make([]string, 0, len(identityProviders))
+	for name := range identityProviders {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Name is the provider name used in routes and stored identities
+func (p *IdentityProvider) Name() string {
+	return p.config.Name
+}
+
+// IsOIDC reports whether the provider issues ID tokens
+func (p *IdentityProvider) IsOIDC() bool {
+	return p.config.Issuer != ""
+}
+
+// AuthorizationURL builds the URL the user is sent to for signing in
+func (p *IdentityProvider) AuthorizationURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid authorization endpoint", ErrIdentityProviderFailed)
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	if p.IsOIDC() {
+		query.Set("nonce", nonce)
+	}
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the user's profile
+func (p *IdentityProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalProfile, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tokens providerTokenResponse
+	if err := p.doJSON(req, &tokens); err != nil {
+		return nil, err
+	}
+
+	var profile *ExternalProfile
+	if p.IsOIDC() {
+		if tokens.IDToken == "" {
+			return nil, fmt.Errorf("%w: no ID token returned", ErrInvalidIDToken)
+		}
+		if profile, err = p.verifyIDToken(ctx, tokens.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	} else {
+		profile = &ExternalProfile{Provider: p.Name()}
+	}
+
+	// Fill in anything the ID token left out from the userinfo endpoint
+	if profile.Email == "" && metadata.UserInfoEndpoint != "" {
+		if tokens.AccessToken == "" {
+			return nil, fmt.Errorf("%w: no access token returned", ErrIdentityProviderFailed)
+		}
+		if err := p.fetchUserInfo(ctx, metadata.UserInfoEndpoint, tokens.AccessToken, profile); err != nil {
+			return nil, err
+		}
+	}
+
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("%w: provider did not identify the user", ErrIdentityProviderFailed)
+	}
+
+	return profile, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token
+func (p *IdentityProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (*ExternalProfile, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.verificationKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce == "" || claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	// With several audiences the token must have been issued to us
+	if audiences, _ := claims.GetAudience(); len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidIDToken)
+		}
+	}
+
+	profile := &ExternalProfile{Provider: p.Name()}
+	p.applyClaims(profile, claims)
+	return profile, nil
+}
+
+// fetchUserInfo loads the user's claims from the userinfo endpoint
+func (p *IdentityProvider) fetchUserInfo(ctx context.Context, endpoint, accessToken string, profile *ExternalProfile) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	claims := map[string]interface{}{}
+	if err := p.doJSON(req, &claims); err != nil {
+		return err
+	}
+
+	subject := claimString(claims, "sub")
+	if subject == "" {
+		// Plain OAuth2 providers such as GitHub use id instead of sub
+		subject = claimString(claims, "id")
+	}
+	if profile.Subject != "" && subject != profile.Subject {
+		return fmt.Errorf("%w: userinfo subject mismatch", ErrIdentityProviderFailed)
+	}
+
+	p.applyClaims(profile, claims)
+	profile.Subject = subject
+	return nil
+}
+
+// applyClaims copies the standard claims into the profile
+func (p *IdentityProvider) applyClaims(profile *ExternalProfile, claims map[string]interface{}) {
+	if subject := claimString(claims, "sub"); subject != "" {
+		profile.Subject = subject
+	}
+	if email := claimString(claims, "email"); email != "" {
+		profile.Email = strings.ToLower(email)
+
+		if _, present := claims["email_verified"]; present {
+			verified, _ := strconv.ParseBool(claimString(claims, "email_verified"))
+			profile.EmailVerified = verified
+		} else {
+			profile.EmailVerified = p.config.TrustEmail
+		}
+	}
+	if name := claimString(claims, "name"); name != "" {
+		profile.Name = name
+	}
+}
+
+// discover returns the provider endpoints, fetching the OIDC discovery
+// document on first use for endpoints that are not configured
+func (p *IdentityProvider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &providerMetadata{
+		Issuer:                p.config.Issuer,
+		AuthorizationEndpoint: p.config.AuthURL,
+		TokenEndpoint:         p.config.TokenURL,
+		UserInfoEndpoint:      p.config.UserInfoURL,
+		JWKSURI:               p.config.JWKSURL,
+	}
+
+	if p.IsOIDC() && (metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var discovered providerMetadata
+		if err := p.doJSON(req, &discovered); err != nil {
+			return nil, err
+		}
+		if discovered.Issuer != p.config.Issuer {
+			return nil, fmt.Errorf("%w: discovery issuer mismatch", ErrIdentityProviderFailed)
+		}
+
+		if metadata.AuthorizationEndpoint == "" {
+			metadata.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+		}
+		if metadata.TokenEndpoint == "" {
+			metadata.TokenEndpoint = discovered.TokenEndpoint
+		}
+		if metadata.UserInfoEndpoint == "" {
+			metadata.UserInfoEndpoint = discovered.UserInfoEndpoint
+		}
+		if metadata.JWKSURI == "" {
+			metadata.JWKSURI = discovered.JWKSURI
+		}
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("%w: missing provider endpoints", ErrIdentityProviderFailed)
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// verificationKey returns the provider's ID token signing key with the given
+// kid, refetching the key set once when the kid is unknown so key rotations
+// are picked up
+func (p *IdentityProvider) verificationKey(ctx context.Context, kid string) (interface{}, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, found := p.keys[kid]; found {
+		return key, nil
+	}
+
+	if metadata.JWKSURI == "" {
+		return nil, errors.New("provider has no JWKS URI")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set utils.JWKSet
+	if err := p.doJSON(req, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if publicKey, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = publicKey
+		}
+	}
+	p.keys = keys
+
+	key, found := keys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// doJSON sends a request and decodes a successful JSON response
+func (p *IdentityProvider) doJSON(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIdentityProviderFailed, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponseBytes))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIdentityProviderFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned status %d", ErrIdentityProviderFailed, req.URL.Host, resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: invalid response from %s", ErrIdentityProviderFailed, req.URL.Host)
+	}
+	return nil
+}
+
+// claimString returns a string, number or boolean claim as a string
+func claimString(claims map[string]interface{}, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	}
+	return ""
+}
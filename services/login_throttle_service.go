@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountLocked    = errors.New("account_locked")
+	ErrTooManyAttempts  = errors.New("too_many_attempts")
+	ErrAccountNotLocked = errors.New("account_not_locked")
+)
+
+const (
+	maxLoginDelay = time.Minute
+	// ipAttemptsPruneWindow is how long idle per-IP counters are kept
+	ipAttemptsPruneWindow = time.Hour
+)
+
+// ThrottleError carries how long the client has to wait before retrying
+type ThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// ipAttempt tracks failed logins coming from a single IP address
+type ipAttempt struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+type ipAttemptTracker struct {
+	mu       sync.Mutex
+	attempts map[string]*ipAttempt
+}
+
+var loginIPAttempts = &ipAttemptTracker{
+	attempts: make(map[string]*ipAttempt),
+}
+
+type LoginThrottleService struct {
+	emailService *EmailService
+}
+
+// NewLoginThrottleService creates a new login throttle service
+func NewLoginThrottleService() *LoginThrottleService {
+	return &LoginThrottleService{
+		emailService: NewEmailService(),
+	}
+}
+
+// Check returns a ThrottleError if the account or IP is locked or must wait
+// before another attempt. user may be nil when the email is unknown.
+func (s *LoginThrottleService) Check(user *models.User, ip string) error {
+	now := time.Now()
+
+	if user != nil {
+		if user.IsLocked() {
+			return &ThrottleError{Err: ErrAccountLocked, RetryAfter: user.LockedUntil.Sub(now)}
+		}
+		if user.LastFailedLoginAt != nil && user.LockedUntil == nil {
+			if wait := user.LastFailedLoginAt.Add(loginDelay(user.FailedLoginAttempts)).Sub(now); wait > 0 {
+				return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: wait}
+			}
+		}
+	}
+
+	loginIPAttempts.mu.Lock()
+	defer loginIPAttempts.mu.Unlock()
+
+	attempt, found := loginIPAttempts.attempts[ip]
+	if !found {
+		return nil
+	}
+	if now.Before(attempt.blockedUntil) {
+		return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: attempt.blockedUntil.Sub(now)}
+	}
+	if wait := attempt.lastFailure.Add(loginDelay(attempt.failures)).Sub(now); wait > 0 {
+		return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login for the IP and, if known, the account.
+// It returns true when this failure locked the account.
+func (s *LoginThrottleService) RecordFailure(user *models.User, ip string) (bool, error) {
+	s.recordIPFailure(ip)
+
+	if user == nil {
+		return false, nil
+	}
+
+	cfg := config.AppConfig
+	now := time.Now()
+
+	// A lockout that has run out starts a fresh count
+	if err := database.DB.Model(&models.User{}).
+		Where("id = ? AND locked_until IS NOT NULL AND locked_until <= ?", user.ID, now).
+		Updates(map[string]interface{}{
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		}).Error; err != nil {
+		return false, err
+	}
+
+	// Count in the database so concurrent failures cannot overwrite each other
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": gorm.Expr("failed_login_attempts + 1"),
+		"last_failed_login_at":  now,
+	}).Error; err != nil {
+		return false, err
+	}
+
+	// Only the failure that crosses the threshold locks the account
+	lockedUntil := now.Add(time.Duration(cfg.LoginLockoutMinutes) * time.Minute)
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND locked_until IS NULL AND failed_login_attempts >= ?", user.ID, cfg.LoginMaxAttempts).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	locked := result.RowsAffected > 0
+
+	if err := database.DB.Select("failed_login_attempts", "last_failed_login_at", "locked_until").
+		First(user, user.ID).Error; err != nil {
+		return false, err
+	}
+
+	if locked {
+		email := user.Email
+		go func() {
+			if err := s.emailService.SendAccountLockedEmail(email, lockedUntil); err != nil {
+				log.Printf("Failed to send account locked email: %v", err)
+			}
+		}()
+	}
+
+	return locked, nil
+}
+
+// RecordSuccess clears the account's failure counter after a successful
+// login. The IP's counter is left to expire, otherwise signing in to an
+// account of one's own would reset the budget for guessing other passwords.
+func (s *LoginThrottleService) RecordSuccess(user *models.User) error {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return nil
+	}
+
+	return s.reset(user)
+}
+
+// Unlock lifts a lockout on behalf of an admin
+func (s *LoginThrottleService) Unlock(user *models.User) error {
+	if !user.IsLocked() && user.FailedLoginAttempts == 0 {
+		return ErrAccountNotLocked
+	}
+
+	return s.reset(user)
+}
+
+// reset clears the account's failure counters and lockout
+func (s *LoginThrottleService) reset(user *models.User) error {
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+
+	return database.DB.Model(user).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+}
+
+// recordIPFailure counts a failed login from an IP and blocks it at the threshold
+func (s *LoginThrottleService) recordIPFailure(ip string) {
+	cfg := config.AppConfig
+	now := time.Now()
+
+	loginIPAttempts.mu.Lock()
+	defer loginIPAttempts.mu.Unlock()
+
+	for key, attempt := range loginIPAttempts.attempts {
+		if now.Sub(attempt.lastFailure) > ipAttemptsPruneWindow && now.After(attempt.blockedUntil) {
+			delete(loginIPAttempts.attempts, key)
+		}
+	}
+
+	attempt, found := loginIPAttempts.attempts[ip]
+	if !found || (!attempt.blockedUntil.IsZero() && now.After(attempt.blockedUntil)) {
+		attempt = &ipAttempt{}
+		loginIPAttempts.attempts[ip] = attempt
+	}
+
+	attempt.failures++
+	attempt.lastFailure = now
+	if attempt.failures >= cfg.LoginIPMaxAttempts {
+		attempt.blockedUntil = now.Add(time.Duration(cfg.LoginLockoutMinutes) * time.Minute)
+	}
+}
+
+// loginDelay is the progressive wait imposed after the given number of
+// consecutive failures: none after the first, then 1s, 2s, 4s... up to a minute
+func loginDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+
+	delay := time.Second << (failures - 2)
+	if delay > maxLoginDelay || delay <= 0 {
+		return maxLoginDelay
+	}
+	return delay
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+func TestLoginThrottleSuccessKeepsIPFailures(t *testing.T) {
+	setupTestDB(t)
+	config.AppConfig.LoginIPMaxAttempts = 3
+	victim := createTestUser(t, "victim@example.com")
+	own := createTestUser(t, "attacker@example.com")
+	service := NewLoginThrottleService()
+	const ip = "198.51.100.7"
+
+	if _, err := service.RecordFailure(own, "198.51.100.8"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Signing in to an account of one's own between guesses must not reset
+	// the budget for guessing other passwords from the same IP
+	for i := 0; i < 3; i++ {
+		if _, err := service.RecordFailure(victim, ip); err != nil {
+			t.Fatal(err)
+		}
+		if err := service.RecordSuccess(own); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var throttleErr *ThrottleError
+	if err := service.Check(nil, ip); !errors.As(err, &throttleErr) || !errors.Is(err, ErrTooManyAttempts) || throttleErr.RetryAfter <= 0 {
+		t.Errorf("Check() error = %v, want the IP blocked", err)
+	}
+
+	var stored models.User
+	if err := database.DB.First(&stored, own.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLoginAttempts != 0 || stored.LastFailedLoginAt != nil {
+		t.Errorf("account failures = %d, want them cleared by the successful login", stored.FailedLoginAttempts)
+	}
+}
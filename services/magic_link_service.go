@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+type MagicLinkService struct {
+	emailChallengeService *EmailChallengeService
+	roleService           *RoleService
+	tokenService          *TokenService
+}
+
+// NewMagicLinkService creates a new magic link service
+func NewMagicLinkService() *MagicLinkService {
+	return &MagicLinkService{
+		emailChallengeService: NewEmailChallengeService(),
+		roleService:           NewRoleService(),
+		tokenService:          NewTokenService(),
+	}
+}
+
+// Confirm consumes a sign-in link token or emailed code and returns the user
+// it was issued to
+func (s *MagicLinkService) Confirm(token, email, code string) (*models.User, error) {
+	var user models.User
+	takeover := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		oneTimeToken, err := s.emailChallengeService.Consume(tx, models.TokenPurposeMagicLink, token, email, code)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, oneTimeToken.UserID).Error; err != nil {
+			return err
+		}
+
+		// Following the link proves the user owns the email address. Whoever
+		// registered it unverified may not, so drop everything they set up.
+		if !user.IsEmailVerified {
+			takeover = true
+			user.IsEmailVerified = true
+			if err := tx.Model(&user).Update("is_email_verified", true).Error; err != nil {
+				return err
+			}
+			if err := ResetAccountCredentials(tx, &user); err != nil {
+				return err
+			}
+			return s.roleService.AssignConfiguredAdmin(tx, &user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign out any sessions the previous holder of the account started
+	if takeover {
+		if _, err := s.tokenService.InvalidateUserTokens(&user, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
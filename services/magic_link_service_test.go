@@ -0,0 +1,79 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+func TestMagicLinkTakesOverUnverifiedAccount(t *testing.T) {
+	setupTestDB(t, &models.Role{}, &models.OneTimeToken{}, &models.Session{}, &models.RefreshToken{},
+		&models.RecoveryCode{}, &models.WebAuthnCredential{}, &models.PersonalAccessToken{}, &models.ExternalIdentity{})
+	for _, name := range []string{models.RoleAdmin, models.RoleUser} {
+		if err := database.DB.Create(&models.Role{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.AppConfig.AdminEmails = []string{"owner@example.com"}
+
+	// Someone registered the owner's email first, set up other ways to sign
+	// in and is still signed in
+	squatter := models.User{Email: "owner@example.com", Name: "Squatter", Password: "Squatter-pass-9"}
+	if err := database.DB.Create(&squatter).Error; err != nil {
+		t.Fatal(err)
+	}
+	addTestCredentials(t, &squatter)
+	session := models.Session{ID: "squatter-session", UserID: squatter.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := NewEmailChallengeService().Issue(database.DB, &squatter, models.TokenPurposeMagicLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := NewMagicLinkService().Confirm(challenge.Secret, "", "")
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if user.ID != squatter.ID || !user.IsEmailVerified {
+		t.Fatalf("Confirm() user = %+v, want verified user %d", user, squatter.ID)
+	}
+
+	assertCredentialsReset(t, squatter.ID)
+
+	var stored models.User
+	if err := database.DB.First(&stored, squatter.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsEmailVerified {
+		t.Error("account was not marked verified")
+	}
+	if stored.TokenVersion <= squatter.TokenVersion {
+		t.Error("squatter's access tokens were not invalidated")
+	}
+
+	if err := database.DB.First(&session, "id = ?", session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.RevokedAt == nil {
+		t.Error("squatter's session was not revoked")
+	}
+
+	roles, err := NewRoleService().RoleNames(database.DB, squatter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(roles, models.RoleAdmin) {
+		t.Errorf("roles = %v, want the configured admin role once verified", roles)
+	}
+
+	if _, err := NewMagicLinkService().Confirm(challenge.Secret, "", ""); err == nil {
+		t.Error("Confirm() accepted a used sign-in link")
+	}
+}
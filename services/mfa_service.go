@@ -0,0 +1,366 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidMFAToken     = errors.New("invalid_mfa_token")
+	ErrInvalidMFACode      = errors.New("invalid_mfa_code")
+	ErrMFAAttemptsExceeded = errors.New("mfa_attempts_exceeded")
+	ErrTOTPAlreadyEnabled  = errors.New("totp_already_enabled")
+	ErrTOTPNotEnabled      = errors.New("totp_not_enabled")
+	ErrTOTPNotInitiated    = errors.New("totp_not_initiated")
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxMFAAttempts is the number of wrong codes allowed per challenge
+	maxMFAAttempts = 5
+)
+
+// challengeAttempts counts failed verifications per MFA challenge token
+type challengeAttempts struct {
+	mu       sync.Mutex
+	attempts map[string]int
+	expiry   map[string]time.Time
+}
+
+var mfaAttempts = &challengeAttempts{
+	attempts: make(map[string]int),
+	expiry:   make(map[string]time.Time),
+}
+
+// fail records a failed attempt and reports whether the limit is reached
+func (a *challengeAttempts) fail(jti string, expiresAt time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range a.expiry {
+		if now.After(expiry) {
+			delete(a.attempts, id)
+			delete(a.expiry, id)
+		}
+	}
+
+	a.attempts[jti]++
+	a.expiry[jti] = expiresAt
+	return a.attempts[jti] >= maxMFAAttempts
+}
+
+type MFAService struct {
+	webauthnService *WebAuthnService
+	throttleService *LoginThrottleService
+}
+
+// NewMFAService creates a new MFA service
+func NewMFAService() *MFAService {
+	return &MFAService{
+		webauthnService: NewWebAuthnService(),
+		throttleService: NewLoginThrottleService(),
+	}
+}
+
+// MFAFactor is the second factor presented to complete a challenge. Exactly
+// one of its fields is used.
+type MFAFactor struct {
+	Code         string
+	RecoveryCode string
+	Passkey      *PasskeyAssertion
+}
+
+// PasskeyAssertion is a passkey assertion answering a WebAuthn session
+type PasskeyAssertion struct {
+	SessionToken string                     `json:"session_token" binding:"required"`
+	Credential   *utils.PublicKeyCredential `json:"credential" binding:"required"`
+}
+
+// BeginTOTPEnrollment generates a new pending TOTP secret for the user
+func (s *MFAService) BeginTOTPEnrollment(user *models.User) (string, string, error) {
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := database.DB.Model(user).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+
+	return secret, utils.TOTPURI(config.AppConfig.AppName, user.Email, secret), nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP once the user proves their authenticator
+// produces valid codes, and returns a fresh set of recovery codes
+func (s *MFAService) ConfirmTOTPEnrollment(user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotInitiated
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":        true,
+			"totp_last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+
+	return codes, err
+}
+
+// DisableTOTP turns off TOTP and deletes the user's recovery codes
+func (s *MFAService) DisableTOTP(user *models.User) error {
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":        false,
+			"totp_secret":         "",
+			"totp_last_used_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes invalidates the existing recovery codes and returns new ones
+func (s *MFAService) RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+
+	return codes, err
+}
+
+// RecoveryCodesRemaining counts the user's unused recovery codes
+func (s *MFAService) RecoveryCodesRemaining(userID uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// IssueChallenge returns the token a client exchanges for an access token
+// once it completes the second factor
+func (s *MFAService) IssueChallenge(user *models.User) (string, error) {
+	return utils.GenerateMFAChallengeToken(user.ID, user.Email)
+}
+
+// BeginPasskeyChallenge creates passkey request options for answering an
+// MFA challenge with one of the user's passkeys
+func (s *MFAService) BeginPasskeyChallenge(challengeToken string) (*CredentialRequestOptions, string, error) {
+	_, user, err := s.parseChallenge(challengeToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.webauthnService.BeginLogin(user, UserVerificationDiscouraged)
+}
+
+// VerifyChallenge validates an MFA challenge token together with a TOTP
+// code, a recovery code or a passkey assertion. The challenge is single-use.
+// Wrong factors count towards the login throttle and lockout of the account,
+// which is only cleared once the second factor succeeds.
+func (s *MFAService) VerifyChallenge(challengeToken string, factor MFAFactor, ip string) (*models.User, error) {
+	claims, user, err := s.parseChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.throttleService.Check(user, ip); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case factor.Passkey != nil:
+		err = s.verifyPasskey(user.ID, factor.Passkey)
+	case factor.RecoveryCode != "":
+		err = s.useRecoveryCode(user.ID, factor.RecoveryCode)
+	case factor.Code != "":
+		err = s.verifyTOTP(user, factor.Code)
+	default:
+		err = ErrInvalidMFACode
+	}
+
+	if errors.Is(err, ErrInvalidMFACode) {
+		locked, err := s.throttleService.RecordFailure(user, ip)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			if err := Revocations.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+				return nil, err
+			}
+			return nil, &ThrottleError{Err: ErrAccountLocked, RetryAfter: time.Until(*user.LockedUntil)}
+		}
+
+		if mfaAttempts.fail(claims.ID, claims.ExpiresAt.Time) {
+			if err := Revocations.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+				return nil, err
+			}
+			return nil, ErrMFAAttemptsExceeded
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Revocations.Revoke(claims.ID, user.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.throttleService.RecordSuccess(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// parseChallenge validates an unused MFA challenge token and loads its user
+func (s *MFAService) parseChallenge(challengeToken string) (*utils.Claims, *models.User, error) {
+	claims := &utils.Claims{}
+	if err := utils.ParseClaims(challengeToken, claims); err != nil || claims.TokenUse != utils.TokenUseMFAChallenge {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	revoked, err := Revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil || user.IsDisabled() {
+		return nil, nil, ErrInvalidMFAToken
+	}
+
+	return claims, &user, nil
+}
+
+// verifyPasskey checks a passkey assertion, treating every verification
+// failure like a wrong code
+func (s *MFAService) verifyPasskey(userID uint, passkey *PasskeyAssertion) error {
+	err := s.webauthnService.VerifyAssertion(userID, passkey.SessionToken, passkey.Credential)
+	if errors.Is(err, utils.ErrWebAuthnVerification) || errors.Is(err, ErrInvalidWebAuthnSession) ||
+		errors.Is(err, ErrPasskeyNotFound) || errors.Is(err, ErrPasskeySignCount) {
+		return ErrInvalidMFACode
+	}
+	return err
+}
+
+// verifyTOTP checks a TOTP code and rejects codes from already used time steps
+func (s *MFAService) verifyTOTP(user *models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrInvalidMFACode
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_used_step < ?", user.ID, step).
+		Update("totp_last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// useRecoveryCode marks a matching unused recovery code as used
+func (s *MFAService) useRecoveryCode(userID uint, code string) error {
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes deletes a user's recovery codes and stores new ones
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateRandomToken(6)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, fmt.Sprintf("%s-%s-%s", raw[0:4], raw[4:8], raw[8:12]))
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(raw),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
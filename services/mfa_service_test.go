@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// setupMFATest stores a user with TOTP enabled and one recovery code
+func setupMFATest(t *testing.T) (*MFAService, *models.User, string) {
+	t.Helper()
+
+	setupTestDB(t, &models.RecoveryCode{})
+	user := createTestUser(t, "mfa@example.com")
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled": true,
+		"totp_secret":  "JBSWY3DPEHPK3PXP",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const recoveryCode = "abcd-efgh-ijkl"
+	if err := database.DB.Create(&models.RecoveryCode{
+		UserID:   user.ID,
+		CodeHash: utils.HashToken(normalizeRecoveryCode(recoveryCode)),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return NewMFAService(), user, recoveryCode
+}
+
+// skipLoginDelay moves the user's last failure back so the progressive delay
+// between attempts does not apply
+func skipLoginDelay(t *testing.T, userID uint) {
+	t.Helper()
+
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).
+		Update("last_failed_login_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMFAFailuresLockAccountAcrossChallenges(t *testing.T) {
+	service, user, recoveryCode := setupMFATest(t)
+	config.AppConfig.LoginMaxAttempts = 3
+
+	// Each login mints a new challenge, but wrong codes keep counting
+	// towards the account lockout even from different IPs
+	for i := 0; i < 2; i++ {
+		ip := fmt.Sprintf("203.0.113.%d", 20+i)
+		challenge, err := service.IssueChallenge(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := service.VerifyChallenge(challenge, MFAFactor{Code: "000000"}, ip); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("VerifyChallenge() error = %v, want %v", err, ErrInvalidMFACode)
+		}
+		skipLoginDelay(t, user.ID)
+	}
+
+	challenge, err := service.IssueChallenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.VerifyChallenge(challenge, MFAFactor{Code: "000000"}, "203.0.113.22"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("VerifyChallenge() error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	challenge, err = service.IssueChallenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var throttleErr *ThrottleError
+	if _, err := service.VerifyChallenge(challenge, MFAFactor{RecoveryCode: recoveryCode}, "203.0.113.23"); !errors.As(err, &throttleErr) || !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("VerifyChallenge() on a locked account error = %v, want %v", err, ErrAccountLocked)
+	}
+}
+
+func TestMFASuccessClearsLoginFailures(t *testing.T) {
+	service, user, recoveryCode := setupMFATest(t)
+	const ip = "203.0.113.11"
+
+	// A failed password attempt before the successful one
+	if _, err := NewLoginThrottleService().RecordFailure(user, ip); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := service.IssueChallenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.VerifyChallenge(challenge, MFAFactor{RecoveryCode: recoveryCode}, ip); err != nil {
+		t.Fatalf("VerifyChallenge() error = %v", err)
+	}
+
+	var stored models.User
+	if err := database.DB.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLoginAttempts != 0 {
+		t.Errorf("failed login attempts = %d, want 0 after passing the second factor", stored.FailedLoginAttempts)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"slices"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOAuthClientNotFound     = errors.New("oauth_client_not_found")
+	ErrInvalidClientMetadata   = errors.New("invalid_client_metadata")
+	ErrInvalidClientRedirect   = errors.New("invalid_redirect_uri")
+	ErrPublicClientHasNoSecret = errors.New("public_client_has_no_secret")
+)
+
+// OAuthClientInput holds the registration metadata of an OAuth client
+type OAuthClientInput struct {
+	Name                   string
+	RedirectURIs           []string
+	PostLogoutRedirectURIs []string
+	GrantTypes             []string
+	Scopes                 []string
+	Confidential           bool
+	SkipConsent            bool
+}
+
+type OAuthClientService struct{}
+
+// NewOAuthClientService creates a new OAuth client service
+func NewOAuthClientService() *OAuthClientService {
+	return &OAuthClientService{}
+}
+
+// Create registers a client and returns it with its secret, which is only
+// available now. Public clients get no secret.
+func (s *OAuthClientService) Create(input OAuthClientInput) (*models.OAuthClient, string, error) {
+	if err := validateClientInput(input); err != nil {
+		return nil, "", err
+	}
+
+	clientID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := models.OAuthClient{ClientID: clientID}
+	applyClientInput(&client, input)
+
+	var secret string
+	if client.Confidential {
+		secret, err = utils.GenerateRandomToken(32)
+		if err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if err := database.DB.Create(&client).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &client, secret, nil
+}
+
+// List returns registered clients, newest first
+func (s *OAuthClientService) List(pagination *utils.Pagination) ([]models.OAuthClient, error) {
+	var total int64
+	if err := database.DB.Model(&models.OAuthClient{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	pagination.SetTotal(total)
+
+	var clients []models.OAuthClient
+	err := database.DB.Order("id DESC").
+		Offset(pagination.Offset()).
+		Limit(pagination.PerPage).
+		Find(&clients).Error
+	return clients, err
+}
+
+// Get returns the client with the given client_id
+func (s *OAuthClientService) Get(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := database.DB.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// Update replaces a client's metadata. Its type cannot change because that
+// would leave a public client with a secret or a confidential one without.
+func (s *OAuthClientService) Update(clientID string, input OAuthClientInput) (*models.OAuthClient, error) {
+	client, err := s.Get(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	input.Confidential = client.Confidential
+	if err := validateClientInput(input); err != nil {
+		return nil, err
+	}
+
+	applyClientInput(client, input)
+	if err := database.DB.Save(client).Error; err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// RotateSecret replaces a confidential client's secret and returns the new one
+func (s *OAuthClientService) RotateSecret(clientID string) (*models.OAuthClient, string, error) {
+	client, err := s.Get(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !client.Confidential {
+		return nil, "", ErrPublicClientHasNoSecret
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client.SecretHash = utils.HashToken(secret)
+	if err := database.DB.Model(client).Update("secret_hash", client.SecretHash).Error; err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+// Delete removes a client together with its codes, tokens and consents
+func (s *OAuthClientService) Delete(clientID string) error {
+	client, err := s.Get(clientID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.OAuthAuthorizationCode{},
+			&models.OAuthRefreshToken{},
+			&models.OAuthDeviceCode{},
+			&models.OAuthConsent{},
+		} {
+			if err := tx.Where("client_id = ?", client.ClientID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(client).Error
+	})
+}
+
+// Authenticate identifies the client making a token request. Confidential
+// clients must present their secret; public clients must not send one.
+func (s *OAuthClientService) Authenticate(clientID, secret string) (*models.OAuthClient, error) {
+	client, err := s.Get(clientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return nil, oauthError(ErrInvalidClient, "Unknown client")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.Confidential {
+		if secret != "" {
+			return nil, oauthError(ErrInvalidClient, "Public clients must not send a secret")
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError(ErrInvalidClient, "Client authentication failed")
+	}
+
+	return client, nil
+}
+
+// validateClientInput checks redirect URIs, grant types and scopes
+func validateClientInput(input OAuthClientInput) error {
+	if input.Name == "" || len(input.GrantTypes) == 0 {
+		return ErrInvalidClientMetadata
+	}
+
+	for _, grantType := range input.GrantTypes {
+		switch grantType {
+		case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeDeviceCode:
+		case models.GrantTypeClientCredentials:
+			// Only a client that can keep a secret can act on its own behalf
+			if !input.Confidential {
+				return ErrInvalidClientMetadata
+			}
+		default:
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	if slices.Contains(input.GrantTypes, models.GrantTypeAuthorizationCode) && len(input.RedirectURIs) == 0 {
+		return ErrInvalidClientRedirect
+	}
+	for _, redirectURI := range slices.Concat(input.RedirectURIs, input.PostLogoutRedirectURIs) {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return ErrInvalidClientRedirect
+		}
+		if (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host == "" {
+			return ErrInvalidClientRedirect
+		}
+	}
+
+	for _, scope := range input.Scopes {
+		if !slices.Contains(config.AppConfig.OAuthServerScopes, scope) {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	return nil
+}
+
+// applyClientInput copies registration metadata onto a client
+func applyClientInput(client *models.OAuthClient, input OAuthClientInput) {
+	client.Name = input.Name
+	client.RedirectURIs = append([]string{}, input.RedirectURIs...)
+	client.PostLogoutRedirectURIs = append([]string{}, input.PostLogoutRedirectURIs...)
+	client.GrantTypes = append([]string{}, input.GrantTypes...)
+	client.Scopes = append([]string{}, input.Scopes...)
+	client.Confidential = input.Confidential
+	client.SkipConsent = input.SkipConsent
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+// slowDownSeconds is added to a device's polling interval each time it
+// polls too fast
+const slowDownSeconds = 5
+
+var ErrInvalidUserCode = errors.New("invalid_user_code")
+
+// DeviceAuthorizationResponse is the device authorization endpoint response
+// defined by RFC 8628
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceAuthorization starts the device flow for a client that cannot
+// receive a redirect, such as a CLI tool
+func (s *OAuthServerService) DeviceAuthorization(client *models.OAuthClient, requestedScope string) (*DeviceAuthorizationResponse, error) {
+	if !client.AllowsGrant(models.GrantTypeDeviceCode) {
+		return nil, oauthError(ErrUnauthorizedClient, "Client may not use the device authorization grant")
+	}
+
+	scope, err := resolveScope(client.Scopes, requestedScope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := utils.GenerateUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(config.AppConfig.OAuthDeviceCodeMinutes) * time.Minute
+	if err := database.DB.Create(&models.OAuthDeviceCode{
+		DeviceCodeHash: utils.HashToken(deviceCode),
+		UserCodeHash:   utils.HashToken(utils.NormalizeUserCode(userCode)),
+		ClientID:       client.ClientID,
+		Scope:          scope,
+		Interval:       config.AppConfig.OAuthDevicePollSeconds,
+		ExpiresAt:      time.Now().Add(ttl),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	verificationURI := config.AppConfig.FrontendURL + "/oauth/device"
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: withQuery(verificationURI, url.Values{"user_code": {userCode}}),
+		ExpiresIn:               int64(ttl.Seconds()),
+		Interval:                config.AppConfig.OAuthDevicePollSeconds,
+	}, nil
+}
+
+// PendingDevice returns the undecided device authorization a user code
+// refers to, together with the client that requested it
+func (s *OAuthServerService) PendingDevice(userCode string) (*models.OAuthDeviceCode, *models.OAuthClient, error) {
+	var device models.OAuthDeviceCode
+	err := database.DB.
+		Where("user_code_hash = ? AND approved_at IS NULL AND denied_at IS NULL AND expires_at > ?",
+			utils.HashToken(utils.NormalizeUserCode(userCode)), time.Now()).
+		First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.clientService.Get(device.ClientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return nil, nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &device, client, nil
+}
+
+// DecideDevice records the user's answer to a device authorization.
+// authTime is when the user signed in.
+func (s *OAuthServerService) DecideDevice(userID uint, authTime time.Time, userCode string, approve bool) error {
+	device, client, err := s.PendingDevice(userCode)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"user_id": userID, "denied_at": now}
+	if approve {
+		updates = map[string]interface{}{"user_id": userID, "approved_at": now, "auth_time": authTime}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so a user code is only answered once
+		result := tx.Model(&models.OAuthDeviceCode{}).
+			Where("id = ? AND approved_at IS NULL AND denied_at IS NULL", device.ID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidUserCode
+		}
+
+		if !approve || client.SkipConsent {
+			return nil
+		}
+		return s.recordConsent(tx, userID, client.ClientID, device.Scope)
+	})
+}
+
+// exchangeDeviceCode answers a device polling for its tokens. Devices that
+// poll faster than their interval are told to slow down.
+func (s *OAuthServerService) exchangeDeviceCode(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	if req.DeviceCode == "" {
+		return nil, oauthError(ErrInvalidOAuthRequest, "device_code is required")
+	}
+
+	var device models.OAuthDeviceCode
+	if err := database.DB.Where("device_code_hash = ?", utils.HashToken(req.DeviceCode)).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(ErrInvalidGrant, "Invalid device code")
+		}
+		return nil, err
+	}
+
+	if device.ClientID != client.ClientID {
+		return nil, oauthError(ErrInvalidGrant, "Invalid device code")
+	}
+	if device.UsedAt != nil {
+		return nil, oauthError(ErrInvalidGrant, "Device code has already been used")
+	}
+
+	now := time.Now()
+	if now.After(device.ExpiresAt) {
+		return nil, oauthError(ErrExpiredToken, "Device code has expired")
+	}
+
+	polls := database.DB.Model(&models.OAuthDeviceCode{}).Where("id = ?", device.ID)
+	if device.LastPolledAt != nil && now.Sub(*device.LastPolledAt) < time.Duration(device.Interval)*time.Second {
+		if err := polls.Updates(map[string]interface{}{
+			"last_polled_at": now,
+			"interval":       device.Interval + slowDownSeconds,
+		}).Error; err != nil {
+			return nil, err
+		}
+		return nil, oauthError(ErrSlowDown, "Polling too frequently")
+	}
+	if err := polls.Update("last_polled_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	if device.DeniedAt != nil {
+		return nil, oauthError(ErrAccessDenied, "The user denied the request")
+	}
+	if device.ApprovedAt == nil || device.UserID == nil {
+		return nil, oauthError(ErrAuthorizationPending, "The user has not yet approved the request")
+	}
+
+	user, err := s.activeUser(*device.UserID)
+	if err != nil {
+		return nil, err
+	}
+	grantID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *OAuthTokenResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthDeviceCode{}).
+			Where("id = ? AND used_at IS NULL", device.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return oauthError(ErrInvalidGrant, "Device code has already been used")
+		}
+
+		grant := oauthGrant{
+			client:  client,
+			user:    user,
+			scope:   device.Scope,
+			grantID: grantID,
+		}
+		if device.AuthTime != nil {
+			grant.authTime = *device.AuthTime
+		}
+
+		var err error
+		response, err = s.issue(tx, grant, device.Scope)
+		return err
+	})
+
+	return response, err
+}
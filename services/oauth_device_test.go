@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// startDeviceTest lets the test client use the device grant and starts a
+// device authorization
+func startDeviceTest(t *testing.T) (*OAuthServerService, *models.OAuthClient, *models.User, *DeviceAuthorizationResponse) {
+	t.Helper()
+
+	service, client, user := setupOAuthServerTest(t)
+	client.GrantTypes = append(client.GrantTypes, models.GrantTypeDeviceCode)
+	if err := database.DB.Save(client).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := service.DeviceAuthorization(client, "openid offline_access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, client, user, device
+}
+
+// pollDevice polls the token endpoint with the device code
+func pollDevice(service *OAuthServerService, client *models.OAuthClient, deviceCode string) (*OAuthTokenResponse, error) {
+	return service.Token(client, &TokenRequest{GrantType: models.GrantTypeDeviceCode, DeviceCode: deviceCode})
+}
+
+// waitDeviceInterval moves the last poll back by the current interval, as if
+// the device had waited before polling again
+func waitDeviceInterval(t *testing.T, deviceCode string) {
+	t.Helper()
+
+	var device models.OAuthDeviceCode
+	if err := database.DB.Where("device_code_hash = ?", utils.HashToken(deviceCode)).First(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	lastPolled := device.LastPolledAt.Add(-time.Duration(device.Interval) * time.Second)
+	if err := database.DB.Model(&device).Update("last_polled_at", lastPolled).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCodePolling(t *testing.T) {
+	service, client, user, device := startDeviceTest(t)
+
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("first poll: got %v, want %v", err, ErrAuthorizationPending)
+	}
+
+	// Polling before the interval has passed slows the device down
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("polling too fast: got %v, want %v", err, ErrSlowDown)
+	}
+	var stored models.OAuthDeviceCode
+	database.DB.Where("device_code_hash = ?", utils.HashToken(device.DeviceCode)).First(&stored)
+	if stored.Interval != device.Interval+slowDownSeconds {
+		t.Fatalf("interval = %d after slow_down, want %d", stored.Interval, device.Interval+slowDownSeconds)
+	}
+
+	waitDeviceInterval(t, device.DeviceCode)
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("polling after the interval: got %v, want %v", err, ErrAuthorizationPending)
+	}
+
+	// The user types the code loosely on another device
+	userCode := strings.ToLower(strings.ReplaceAll(device.UserCode, "-", " "))
+	if err := service.DecideDevice(user.ID, time.Now(), userCode, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DecideDevice(user.ID, time.Now(), device.UserCode, false); !errors.Is(err, ErrInvalidUserCode) {
+		t.Fatalf("answering twice: got %v, want %v", err, ErrInvalidUserCode)
+	}
+
+	// Another client cannot redeem the device code
+	other := *client
+	other.ClientID = "other-client"
+	waitDeviceInterval(t, device.DeviceCode)
+	if _, err := pollDevice(service, &other, device.DeviceCode); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("polling as another client: got %v, want %v", err, ErrInvalidGrant)
+	}
+
+	tokens, err := pollDevice(service, client, device.DeviceCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IDToken == "" {
+		t.Fatalf("got %+v, want access, refresh and ID tokens", tokens)
+	}
+
+	waitDeviceInterval(t, device.DeviceCode)
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("polling after redeeming: got %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestDeviceCodeDenied(t *testing.T) {
+	service, client, user, device := startDeviceTest(t)
+
+	if err := service.DecideDevice(user.ID, time.Now(), device.UserCode, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("got %v, want %v", err, ErrAccessDenied)
+	}
+}
+
+func TestDeviceCodeExpired(t *testing.T) {
+	service, client, user, device := startDeviceTest(t)
+
+	if err := database.DB.Model(&models.OAuthDeviceCode{}).Where("device_code_hash = ?", utils.HashToken(device.DeviceCode)).
+		Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.DecideDevice(user.ID, time.Now(), device.UserCode, true); !errors.Is(err, ErrInvalidUserCode) {
+		t.Fatalf("approving an expired code: got %v, want %v", err, ErrInvalidUserCode)
+	}
+	if _, err := pollDevice(service, client, device.DeviceCode); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("polling an expired code: got %v, want %v", err, ErrExpiredToken)
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+// Token type hints defined by RFC 7009
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse is the token introspection response defined by
+// RFC 7662. Inactive tokens are described by Active alone.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	JWTID     string   `json:"jti,omitempty"`
+	UserID    uint     `json:"user_id,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// Introspect reports whether a token is currently accepted by this server,
+// taking logout, password changes and revocation into account. Access tokens
+// of any client can be introspected; refresh tokens only by their client.
+func (s *OAuthServerService) Introspect(client *models.OAuthClient, token, tokenTypeHint string) (*IntrospectionResponse, error) {
+	if !client.Confidential {
+		return nil, oauthError(ErrInvalidClient, "Public clients may not introspect tokens")
+	}
+
+	lookups := []func(*models.OAuthClient, string) (*IntrospectionResponse, error){
+		s.introspectAccessToken,
+		s.introspectRefreshToken,
+	}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		response, err := lookup(client, token)
+		if err != nil || response.Active {
+			return response, err
+		}
+	}
+
+	return &IntrospectionResponse{Active: false}, nil
+}
+
+// RevokeToken revokes an access or refresh token issued to the client.
+// Unknown tokens and tokens of other clients are ignored, as RFC 7009
+// requires the same response either way.
+func (s *OAuthServerService) RevokeToken(client *models.OAuthClient, token, tokenTypeHint string) error {
+	revocations := []func(*models.OAuthClient, string) (bool, error){
+		s.revokeAccessToken,
+		s.revokeRefreshToken,
+	}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		revocations[0], revocations[1] = revocations[1], revocations[0]
+	}
+
+	for _, revoke := range revocations {
+		found, err := revoke(client, token)
+		if err != nil || found {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// introspectAccessToken checks a signed access token the way the API and the
+// OAuth token middleware would
+func (s *OAuthServerService) introspectAccessToken(_ *models.OAuthClient, token string) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+
+	claims, err := utils.ValidateToken(token)
+	if err != nil || claims.TokenUse != utils.TokenUseAccess || claims.ID == "" {
+		return inactive, nil
+	}
+
+	revoked, err := Revocations.IsRevoked(claims.ID)
+	if err != nil || revoked {
+		return inactive, err
+	}
+
+	// Client credentials tokens have no user
+	if claims.UserID != 0 || claims.ClientID == "" {
+		version, err := s.tokenService.TokenVersion(claims.UserID)
+		if errors.Is(err, ErrTokenInvalidated) {
+			return inactive, nil
+		}
+		if err != nil || version != claims.TokenVersion {
+			return inactive, err
+		}
+	}
+
+	// First-party tokens end with their session
+	if claims.ClientID == "" {
+		active, err := s.sessionService.Active(claims.SessionID)
+		if err != nil || !active {
+			return inactive, err
+		}
+	}
+
+	response := &IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  claims.Email,
+		TokenType: "Bearer",
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		JWTID:     claims.ID,
+		UserID:    claims.UserID,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
+		Roles:     claims.Roles,
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response.NotBefore = claims.NotBefore.Unix()
+	}
+	return response, nil
+}
+
+// introspectRefreshToken checks a refresh token issued to the client
+func (s *OAuthServerService) introspectRefreshToken(client *models.OAuthClient, token string) (*IntrospectionResponse, error) {
+	inactive := &IntrospectionResponse{Active: false}
+
+	stored, err := s.clientRefreshToken(client, token)
+	if err != nil || stored == nil {
+		return inactive, err
+	}
+	if stored.UsedAt != nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return inactive, nil
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, stored.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if user.IsDisabled() || user.TokenVersion != stored.TokenVersion {
+		return inactive, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     stored.Scope,
+		ClientID:  stored.ClientID,
+		Username:  user.Email,
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Subject:   strconv.FormatUint(uint64(user.ID), 10),
+		UserID:    user.ID,
+	}, nil
+}
+
+// revokeAccessToken adds an access token issued to the client to the
+// revocation list
+func (s *OAuthServerService) revokeAccessToken(client *models.OAuthClient, token string) (bool, error) {
+	claims, err := utils.ValidateToken(token)
+	if err != nil || claims.TokenUse != utils.TokenUseAccess || claims.ID == "" || claims.ClientID != client.ClientID {
+		return false, nil
+	}
+
+	return true, Revocations.Revoke(claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// revokeRefreshToken revokes a refresh token issued to the client together
+// with the rest of its grant
+func (s *OAuthServerService) revokeRefreshToken(client *models.OAuthClient, token string) (bool, error) {
+	stored, err := s.clientRefreshToken(client, token)
+	if err != nil || stored == nil {
+		return false, err
+	}
+
+	return true, s.revokeGrant(stored.GrantID)
+}
+
+// clientRefreshToken looks up a refresh token issued to the client
+func (s *OAuthServerService) clientRefreshToken(client *models.OAuthClient, token string) (*models.OAuthRefreshToken, error) {
+	var stored models.OAuthRefreshToken
+	err := database.DB.Where("token_hash = ? AND client_id = ?", utils.HashToken(token), client.ClientID).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
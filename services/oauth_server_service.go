@@ -0,0 +1,751 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+// Scopes with a special meaning to the authorization server
+const (
+	// ScopeOpenID requests an OpenID Connect ID token
+	ScopeOpenID = "openid"
+	// ScopeOfflineAccess lets a client obtain refresh tokens
+	ScopeOfflineAccess = "offline_access"
+)
+
+// OAuthError is reported to OAuth clients using one of the error codes
+// defined by RFC 6749
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code
+}
+
+// Is matches OAuth errors by code so described errors match their sentinel
+func (e *OAuthError) Is(target error) bool {
+	t, ok := target.(*OAuthError)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrInvalidOAuthRequest     = &OAuthError{Code: "invalid_request"}
+	ErrInvalidClient           = &OAuthError{Code: "invalid_client"}
+	ErrInvalidGrant            = &OAuthError{Code: "invalid_grant"}
+	ErrUnauthorizedClient      = &OAuthError{Code: "unauthorized_client"}
+	ErrUnsupportedGrantType    = &OAuthError{Code: "unsupported_grant_type"}
+	ErrUnsupportedResponseType = &OAuthError{Code: "unsupported_response_type"}
+	ErrInvalidScope            = &OAuthError{Code: "invalid_scope"}
+	ErrAccessDenied            = &OAuthError{Code: "access_denied"}
+	ErrAuthorizationPending    = &OAuthError{Code: "authorization_pending"}
+	ErrSlowDown                = &OAuthError{Code: "slow_down"}
+	ErrExpiredToken            = &OAuthError{Code: "expired_token"}
+	ErrConsentNotFound         = errors.New("consent_not_found")
+)
+
+// AuthorizationRequest holds the parameters of an authorization request
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+// TokenRequest holds the parameters of a token endpoint request
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	DeviceCode   string `form:"device_code"`
+	Scope        string `form:"scope"`
+}
+
+// OAuthTokenResponse is the token endpoint response defined by RFC 6749
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthConsentInfo describes a client the user has granted access to
+type OAuthConsentInfo struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// oauthGrant is the authorization tokens are issued for. Refresh tokens
+// carry the grant's scope even when an access token is narrowed.
+type oauthGrant struct {
+	client   *models.OAuthClient
+	user     *models.User
+	scope    string
+	grantID  string
+	nonce    string
+	authTime time.Time
+}
+
+type OAuthServerService struct {
+	clientService  *OAuthClientService
+	tokenService   *TokenService
+	sessionService *SessionService
+}
+
+// NewOAuthServerService creates a new OAuth authorization server service
+func NewOAuthServerService() *OAuthServerService {
+	return &OAuthServerService{
+		clientService:  NewOAuthClientService(),
+		tokenService:   NewTokenService(),
+		sessionService: NewSessionService(),
+	}
+}
+
+// ValidateAuthorization checks an authorization request, normalizing its
+// scope, and returns the client and the redirect URI to answer to. Errors
+// other than ErrInvalidClient and ErrInvalidClientRedirect must be reported
+// to the client through the redirect URI.
+func (s *OAuthServerService) ValidateAuthorization(req *AuthorizationRequest) (*models.OAuthClient, string, error) {
+	client, err := s.clientService.Get(req.ClientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return nil, "", oauthError(ErrInvalidClient, "Unknown client")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	redirectURI, err := resolveRedirectURI(client, req.RedirectURI)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if req.ResponseType != "code" {
+		return client, redirectURI, oauthError(ErrUnsupportedResponseType, "Only the code response type is supported")
+	}
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return client, redirectURI, oauthError(ErrUnauthorizedClient, "Client may not use the authorization code grant")
+	}
+
+	scope, err := resolveScope(client.Scopes, req.Scope)
+	if err != nil {
+		return client, redirectURI, err
+	}
+	req.Scope = scope
+
+	// Without a method the challenge is the verifier itself (RFC 7636 §4.3)
+	if req.CodeChallenge != "" && req.CodeChallengeMethod == "" {
+		req.CodeChallengeMethod = utils.CodeChallengeMethodPlain
+	}
+
+	switch {
+	case req.CodeChallenge == "" && !client.Confidential:
+		return client, redirectURI, oauthError(ErrInvalidOAuthRequest, "Public clients must use PKCE")
+	case req.CodeChallenge == "":
+	case req.CodeChallengeMethod != utils.CodeChallengeMethodS256 && req.CodeChallengeMethod != utils.CodeChallengeMethodPlain:
+		return client, redirectURI, oauthError(ErrInvalidOAuthRequest, "code_challenge_method must be S256 or plain")
+	case len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128:
+		return client, redirectURI, oauthError(ErrInvalidOAuthRequest, "Invalid code_challenge")
+	}
+
+	if len(req.Nonce) > 255 {
+		return client, redirectURI, oauthError(ErrInvalidOAuthRequest, "nonce is too long")
+	}
+
+	return client, redirectURI, nil
+}
+
+// ConsentRequired reports whether the user has yet to approve the scope
+func (s *OAuthServerService) ConsentRequired(userID uint, client *models.OAuthClient, scope string) (bool, error) {
+	if client.SkipConsent {
+		return false, nil
+	}
+
+	var consent models.OAuthConsent
+	err := database.DB.Where("user_id = ? AND client_id = ?", userID, client.ClientID).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return !scopeCovers(consent.Scope, scope), nil
+}
+
+// Authorize records the user's consent to a validated request and returns
+// the redirect URI carrying a new authorization code. authTime is when the
+// user signed in.
+func (s *OAuthServerService) Authorize(userID uint, authTime time.Time, client *models.OAuthClient, redirectURI string, req *AuthorizationRequest) (string, error) {
+	code, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	grantID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if !client.SkipConsent {
+			if err := s.recordConsent(tx, userID, client.ClientID, req.Scope); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.OAuthAuthorizationCode{
+			CodeHash:            utils.HashToken(code),
+			ClientID:            client.ClientID,
+			UserID:              userID,
+			RedirectURI:         redirectURI,
+			RedirectURIIncluded: req.RedirectURI != "",
+			Scope:               req.Scope,
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+			Nonce:               req.Nonce,
+			AuthTime:            authTime,
+			GrantID:             grantID,
+			ExpiresAt:           time.Now().Add(time.Duration(config.AppConfig.OAuthCodeMinutes) * time.Minute),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return withQuery(redirectURI, params), nil
+}
+
+// AuthorizationErrorRedirect returns the redirect URI reporting err to the
+// client
+func AuthorizationErrorRedirect(redirectURI string, err error, state string) string {
+	params := url.Values{}
+
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		params.Set("error", oauthErr.Code)
+		if oauthErr.Description != "" {
+			params.Set("error_description", oauthErr.Description)
+		}
+	} else {
+		params.Set("error", "server_error")
+	}
+
+	if state != "" {
+		params.Set("state", state)
+	}
+	return withQuery(redirectURI, params)
+}
+
+// Token handles a token request from an authenticated client
+func (s *OAuthServerService) Token(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	if req.GrantType == "" {
+		return nil, oauthError(ErrInvalidOAuthRequest, "grant_type is required")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials, models.GrantTypeDeviceCode:
+	default:
+		return nil, oauthError(ErrUnsupportedGrantType, "Unsupported grant type")
+	}
+
+	if !client.AllowsGrant(req.GrantType) {
+		return nil, oauthError(ErrUnauthorizedClient, "Client may not use this grant type")
+	}
+
+	switch req.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		return s.exchangeCode(client, req)
+	case models.GrantTypeRefreshToken:
+		return s.refresh(client, req)
+	case models.GrantTypeDeviceCode:
+		return s.exchangeDeviceCode(client, req)
+	default:
+		return s.clientCredentials(client, req)
+	}
+}
+
+// ListConsents returns the clients the user has granted access to
+func (s *OAuthServerService) ListConsents(userID uint) ([]OAuthConsentInfo, error) {
+	var consents []models.OAuthConsent
+	if err := database.DB.Where("user_id = ?", userID).Order("updated_at DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+
+	clientIDs := make([]string, 0, len(consents))
+	for _, consent := range consents {
+		clientIDs = append(clientIDs, consent.ClientID)
+	}
+
+	var clients []models.OAuthClient
+	if err := database.DB.Where("client_id IN ?", clientIDs).Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(clients))
+	for _, client := range clients {
+		names[client.ClientID] = client.Name
+	}
+
+	infos := make([]OAuthConsentInfo, 0, len(consents))
+	for _, consent := range consents {
+		infos = append(infos, OAuthConsentInfo{
+			ClientID:   consent.ClientID,
+			ClientName: names[consent.ClientID],
+			Scopes:     strings.Fields(consent.Scope),
+			CreatedAt:  consent.CreatedAt,
+			UpdatedAt:  consent.UpdatedAt,
+		})
+	}
+
+	return infos, nil
+}
+
+// RevokeConsent withdraws the user's consent to a client and revokes every
+// refresh token and unused code or device authorization the client holds
+// for the user
+func (s *OAuthServerService) RevokeConsent(userID uint, clientID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&models.OAuthConsent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConsentNotFound
+		}
+
+		if err := tx.Where("user_id = ? AND client_id = ? AND used_at IS NULL", userID, clientID).
+			Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND client_id = ? AND used_at IS NULL", userID, clientID).
+			Delete(&models.OAuthDeviceCode{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.OAuthRefreshToken{}).
+			Where("user_id = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// Purge deletes expired authorization codes, device authorizations and
+// client access and refresh tokens
+func (s *OAuthServerService) Purge() error {
+	now := time.Now()
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.OAuthAuthorizationCode{}).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.OAuthAccessToken{}).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("expires_at < ?", now).Delete(&models.OAuthDeviceCode{}).Error; err != nil {
+		return err
+	}
+	return database.DB.Where("expires_at < ?", now).Delete(&models.OAuthRefreshToken{}).Error
+}
+
+// exchangeCode redeems an authorization code. Redeeming a code twice
+// revokes the access and refresh tokens issued from it, as the code has
+// likely been stolen.
+func (s *OAuthServerService) exchangeCode(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	var code models.OAuthAuthorizationCode
+	if err := database.DB.Where("code_hash = ?", utils.HashToken(req.Code)).First(&code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(ErrInvalidGrant, "Invalid authorization code")
+		}
+		return nil, err
+	}
+
+	if code.ClientID != client.ClientID {
+		return nil, oauthError(ErrInvalidGrant, "Invalid authorization code")
+	}
+	if code.UsedAt != nil {
+		if err := s.revokeGrant(code.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(ErrInvalidGrant, "Authorization code has already been used")
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return nil, oauthError(ErrInvalidGrant, "Authorization code has expired")
+	}
+
+	// The redirect URI must be repeated if the authorization request included
+	// it, and may otherwise be left out (RFC 6749 §4.1.3)
+	if req.RedirectURI != code.RedirectURI && (req.RedirectURI != "" || code.RedirectURIIncluded) {
+		return nil, oauthError(ErrInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+
+	if code.CodeChallenge != "" {
+		if !utils.VerifyCodeChallenge(code.CodeChallengeMethod, code.CodeChallenge, req.CodeVerifier) {
+			return nil, oauthError(ErrInvalidGrant, "PKCE verification failed")
+		}
+	}
+
+	user, err := s.activeUser(code.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *OAuthTokenResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so a code cannot be redeemed twice concurrently
+		result := tx.Model(&models.OAuthAuthorizationCode{}).
+			Where("id = ? AND used_at IS NULL", code.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidGrant
+		}
+
+		var err error
+		response, err = s.issue(tx, oauthGrant{
+			client:   client,
+			user:     user,
+			scope:    code.Scope,
+			grantID:  code.GrantID,
+			nonce:    code.Nonce,
+			authTime: code.AuthTime,
+		}, code.Scope)
+		return err
+	})
+	if errors.Is(err, ErrInvalidGrant) {
+		if err := s.revokeGrant(code.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(ErrInvalidGrant, "Authorization code has already been used")
+	}
+
+	return response, err
+}
+
+// refresh rotates a client refresh token. Presenting a rotated token again
+// revokes the whole grant.
+func (s *OAuthServerService) refresh(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	var stored models.OAuthRefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(ErrInvalidGrant, "Invalid refresh token")
+		}
+		return nil, err
+	}
+
+	if stored.ClientID != client.ClientID || stored.RevokedAt != nil {
+		return nil, oauthError(ErrInvalidGrant, "Invalid refresh token")
+	}
+	if stored.UsedAt != nil {
+		if err := s.revokeGrant(stored.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(ErrInvalidGrant, "Refresh token has already been used")
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, oauthError(ErrInvalidGrant, "Refresh token has expired")
+	}
+
+	scope := stored.Scope
+	if req.Scope != "" {
+		if !scopeCovers(stored.Scope, req.Scope) {
+			return nil, oauthError(ErrInvalidScope, "Requested scope exceeds the original grant")
+		}
+		scope = strings.Join(uniqueScopes(req.Scope), " ")
+	}
+
+	user, err := s.activeUser(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TokenVersion != stored.TokenVersion {
+		return nil, oauthError(ErrInvalidGrant, "Refresh token is no longer valid")
+	}
+
+	var response *OAuthTokenResponse
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthRefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidGrant
+		}
+
+		var err error
+		response, err = s.issue(tx, oauthGrant{
+			client:   client,
+			user:     user,
+			scope:    stored.Scope,
+			grantID:  stored.GrantID,
+			authTime: stored.AuthTime,
+		}, scope)
+		return err
+	})
+	if errors.Is(err, ErrInvalidGrant) {
+		if err := s.revokeGrant(stored.GrantID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(ErrInvalidGrant, "Refresh token has already been used")
+	}
+
+	return response, err
+}
+
+// clientCredentials issues an access token to a confidential client acting
+// on its own behalf
+func (s *OAuthServerService) clientCredentials(client *models.OAuthClient, req *TokenRequest) (*OAuthTokenResponse, error) {
+	allowed := slices.DeleteFunc(slices.Clone(client.Scopes), func(scope string) bool {
+		return scope == ScopeOfflineAccess || scope == ScopeOpenID
+	})
+
+	scope, err := resolveScope(allowed, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(database.DB, oauthGrant{client: client, scope: scope}, scope)
+}
+
+// issue signs an access token for the grant, an ID token when the openid
+// scope is granted and, when the grant includes offline access, a refresh
+// token bound to it
+func (s *OAuthServerService) issue(db *gorm.DB, grant oauthGrant, scope string) (*OAuthTokenResponse, error) {
+	claims := utils.Claims{
+		Scope:    scope,
+		ClientID: grant.client.ClientID,
+	}
+	claims.Issuer = config.AppConfig.OAuthIssuer
+	claims.Subject = grant.client.ClientID
+	if grant.user != nil {
+		claims.UserID = grant.user.ID
+		claims.TokenVersion = grant.user.TokenVersion
+		claims.Subject = strconv.FormatUint(uint64(grant.user.ID), 10)
+		if hasScope(scope, "email") {
+			claims.Email = grant.user.Email
+		}
+	}
+
+	// Access tokens issued for a user are recorded so they can be revoked
+	// with the grant
+	if grant.grantID != "" {
+		tokenID, err := utils.GenerateRandomToken(16)
+		if err != nil {
+			return nil, err
+		}
+		claims.ID = tokenID
+	}
+
+	accessToken, err := utils.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if grant.grantID != "" {
+		if err := db.Create(&models.OAuthAccessToken{
+			JTI:       claims.ID,
+			GrantID:   grant.grantID,
+			UserID:    grant.user.ID,
+			ExpiresAt: time.Now().Add(utils.AccessTokenTTL()),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	response := &OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(utils.AccessTokenTTL().Seconds()),
+		Scope:       scope,
+	}
+
+	if grant.user != nil && hasScope(scope, ScopeOpenID) {
+		response.IDToken, err = s.idToken(grant, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if grant.user == nil || !hasScope(grant.scope, ScopeOfflineAccess) ||
+		!grant.client.AllowsGrant(models.GrantTypeRefreshToken) {
+		return response, nil
+	}
+
+	rawRefreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Create(&models.OAuthRefreshToken{
+		TokenHash:    utils.HashToken(rawRefreshToken),
+		ClientID:     grant.client.ClientID,
+		UserID:       grant.user.ID,
+		Scope:        grant.scope,
+		GrantID:      grant.grantID,
+		TokenVersion: grant.user.TokenVersion,
+		AuthTime:     grant.authTime,
+		ExpiresAt:    time.Now().AddDate(0, 0, config.AppConfig.RefreshTokenDays),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	response.RefreshToken = rawRefreshToken
+	return response, nil
+}
+
+// activeUser loads the user a grant was made by, rejecting the grant if the
+// account has since been deleted or disabled
+func (s *OAuthServerService) activeUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError(ErrInvalidGrant, "User no longer exists")
+		}
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, oauthError(ErrInvalidGrant, "User account is disabled")
+	}
+	return &user, nil
+}
+
+// revokeGrant revokes every refresh token issued from a grant along with the
+// access tokens that have not expired yet
+func (s *OAuthServerService) revokeGrant(grantID string) error {
+	if err := database.DB.Model(&models.OAuthRefreshToken{}).
+		Where("grant_id = ? AND revoked_at IS NULL", grantID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	var accessTokens []models.OAuthAccessToken
+	if err := database.DB.Where("grant_id = ? AND expires_at > ?", grantID, time.Now()).Find(&accessTokens).Error; err != nil {
+		return err
+	}
+	for _, token := range accessTokens {
+		if err := Revocations.Revoke(token.JTI, token.UserID, token.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordConsent adds the scope to the user's consent to the client
+func (s *OAuthServerService) recordConsent(tx *gorm.DB, userID uint, clientID, scope string) error {
+	var consent models.OAuthConsent
+	err := tx.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&models.OAuthConsent{UserID: userID, ClientID: clientID, Scope: scope}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	merged := strings.Join(uniqueScopes(consent.Scope+" "+scope), " ")
+	return tx.Model(&consent).Update("scope", merged).Error
+}
+
+// oauthError returns an OAuth error with a human-readable description
+func oauthError(base *OAuthError, description string) error {
+	return &OAuthError{Code: base.Code, Description: description}
+}
+
+// resolveRedirectURI returns the registered redirect URI a request refers to.
+// It may only be left out when the client has a single one.
+func resolveRedirectURI(client *models.OAuthClient, redirectURI string) (string, error) {
+	if redirectURI == "" {
+		if len(client.RedirectURIs) == 1 {
+			return client.RedirectURIs[0], nil
+		}
+		return "", ErrInvalidClientRedirect
+	}
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return "", ErrInvalidClientRedirect
+	}
+	return redirectURI, nil
+}
+
+// resolveScope checks the requested scope against the allowed scopes and the
+// scopes this server supports. An empty request is granted every allowed scope.
+func resolveScope(allowed []string, requested string) (string, error) {
+	scopes := uniqueScopes(requested)
+	if requested == "" {
+		scopes = allowed
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) || !slices.Contains(config.AppConfig.OAuthServerScopes, scope) {
+			return "", oauthError(ErrInvalidScope, "Scope "+scope+" is not allowed")
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+// uniqueScopes splits a space-separated scope, dropping duplicates
+func uniqueScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// hasScope reports whether a space-separated scope includes the given one
+func hasScope(scope, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
+
+// scopeCovers reports whether every requested scope has been granted
+func scopeCovers(granted, requested string) bool {
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range strings.Fields(requested) {
+		if !slices.Contains(grantedScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// withQuery adds parameters to the query of a redirect URI
+func withQuery(redirectURI string, params url.Values) string {
+	parsed, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+const testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+// setupOAuthServerTest registers a public client allowed to use the
+// authorization code and refresh token grants
+func setupOAuthServerTest(t *testing.T) (*OAuthServerService, *models.OAuthClient, *models.User) {
+	t.Helper()
+
+	setupTestDB(t, &models.Session{}, &models.OAuthClient{}, &models.OAuthAuthorizationCode{},
+		&models.OAuthRefreshToken{}, &models.OAuthAccessToken{}, &models.OAuthDeviceCode{}, &models.OAuthConsent{})
+
+	client := models.OAuthClient{
+		ClientID:     "test-client",
+		Name:         "Test Client",
+		RedirectURIs: []string{"https://client.example.com/callback"},
+		GrantTypes:   []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken},
+		Scopes:       []string{"openid", "email", "offline_access"},
+	}
+	if err := database.DB.Create(&client).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return NewOAuthServerService(), &client, createTestUser(t, "user@example.com")
+}
+
+// authorizeCode runs an authorization request for the user and returns the
+// code the client is redirected with
+func authorizeCode(t *testing.T, service *OAuthServerService, user *models.User, req *AuthorizationRequest) string {
+	t.Helper()
+
+	req.ResponseType = "code"
+	req.ClientID = "test-client"
+	client, redirectURI, err := service.ValidateAuthorization(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := service.Authorize(user.ID, time.Now(), client, redirectURI, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redirect.Query().Get("code")
+}
+
+func TestAuthorizationCodeReplayRevokesIssuedTokens(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+
+	code := authorizeCode(t, service, user, &AuthorizationRequest{
+		Scope:               "openid offline_access",
+		CodeChallenge:       utils.CodeChallengeS256(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	})
+	req := &TokenRequest{
+		GrantType:    models.GrantTypeAuthorizationCode,
+		Code:         code,
+		CodeVerifier: testCodeVerifier,
+	}
+
+	tokens, err := service.Token(client, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := service.Token(client, &TokenRequest{GrantType: models.GrantTypeRefreshToken, RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Token(client, req); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("replaying the code: got %v, want %v", err, ErrInvalidGrant)
+	}
+
+	for _, accessToken := range []string{tokens.AccessToken, refreshed.AccessToken} {
+		claims, err := utils.ValidateToken(accessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked, err := Revocations.IsRevoked(claims.ID); err != nil || !revoked {
+			t.Fatalf("access token issued from the replayed code is still valid (revoked %v, err %v)", revoked, err)
+		}
+	}
+
+	_, err = service.Token(client, &TokenRequest{GrantType: models.GrantTypeRefreshToken, RefreshToken: refreshed.RefreshToken})
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("refresh token issued from the replayed code: got %v, want %v", err, ErrInvalidGrant)
+	}
+}
+
+func TestTokenRequestMustRepeatIncludedRedirectURI(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+	challenge := utils.CodeChallengeS256(testCodeVerifier)
+
+	tests := []struct {
+		name         string
+		authorizeURI string
+		tokenURI     string
+		wantRejected bool
+	}{
+		{"included and repeated", "https://client.example.com/callback", "https://client.example.com/callback", false},
+		{"included but left out", "https://client.example.com/callback", "", true},
+		{"left out both times", "", "", false},
+		{"left out but sent later", "", "https://client.example.com/callback", false},
+		{"different URI", "", "https://client.example.com/other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := authorizeCode(t, service, user, &AuthorizationRequest{
+				RedirectURI:         tt.authorizeURI,
+				Scope:               "openid",
+				CodeChallenge:       challenge,
+				CodeChallengeMethod: "S256",
+			})
+
+			_, err := service.Token(client, &TokenRequest{
+				GrantType:    models.GrantTypeAuthorizationCode,
+				Code:         code,
+				RedirectURI:  tt.tokenURI,
+				CodeVerifier: testCodeVerifier,
+			})
+			if tt.wantRejected {
+				if !errors.Is(err, ErrInvalidGrant) {
+					t.Fatalf("got %v, want %v", err, ErrInvalidGrant)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCodePKCE(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+	s256 := utils.CodeChallengeS256(testCodeVerifier)
+
+	tests := []struct {
+		name         string
+		challenge    string
+		method       string
+		verifier     string
+		wantRejected bool
+	}{
+		{"S256", s256, "S256", testCodeVerifier, false},
+		{"S256 with a mismatched verifier", s256, "S256", testCodeVerifier[1:] + "x", true},
+		{"S256 with the challenge as verifier", s256, "S256", s256, true},
+		{"S256 without a verifier", s256, "S256", "", true},
+		{"plain", testCodeVerifier, "plain", testCodeVerifier, false},
+		{"plain with a mismatched verifier", testCodeVerifier, "plain", testCodeVerifier[1:] + "x", true},
+		{"plain without a verifier", testCodeVerifier, "plain", "", true},
+		{"method defaults to plain", testCodeVerifier, "", testCodeVerifier, false},
+		{"default method with an S256 challenge", s256, "", testCodeVerifier, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := authorizeCode(t, service, user, &AuthorizationRequest{
+				Scope:               "openid",
+				CodeChallenge:       tt.challenge,
+				CodeChallengeMethod: tt.method,
+			})
+
+			_, err := service.Token(client, &TokenRequest{
+				GrantType:    models.GrantTypeAuthorizationCode,
+				Code:         code,
+				CodeVerifier: tt.verifier,
+			})
+			if tt.wantRejected {
+				if !errors.Is(err, ErrInvalidGrant) {
+					t.Fatalf("got %v, want %v", err, ErrInvalidGrant)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAuthorizationRequestPKCEValidation(t *testing.T) {
+	service, _, _ := setupOAuthServerTest(t)
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+	}{
+		{"public client without a challenge", "", ""},
+		{"unknown method", utils.CodeChallengeS256(testCodeVerifier), "S512"},
+		{"challenge too short", "abc", "plain"},
+	}
+	for _, tt := range tests {
+		_, _, err := service.ValidateAuthorization(&AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            "test-client",
+			Scope:               "openid",
+			CodeChallenge:       tt.challenge,
+			CodeChallengeMethod: tt.method,
+		})
+		if !errors.Is(err, ErrInvalidOAuthRequest) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidOAuthRequest)
+		}
+	}
+}
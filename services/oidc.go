@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// OpenIDConfiguration is the OpenID Connect discovery document
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// UserInfo is the userinfo endpoint response
+type UserInfo struct {
+	Subject string `json:"sub"`
+	utils.OIDCUserClaims
+}
+
+// LogoutRequest holds the parameters of an RP-initiated logout request
+type LogoutRequest struct {
+	IDTokenHint           string `form:"id_token_hint" json:"id_token_hint"`
+	ClientID              string `form:"client_id" json:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri" json:"post_logout_redirect_uri"`
+	State                 string `form:"state" json:"state"`
+}
+
+// Discovery returns the OpenID Connect discovery document of this server
+func (s *OAuthServerService) Discovery() OpenIDConfiguration {
+	issuer := config.AppConfig.OAuthIssuer
+
+	var algorithms []string
+	if key := utils.SigningKey(); key != nil {
+		algorithms = []string{key.Algorithm}
+	}
+
+	return OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		EndSessionEndpoint:                issuer + "/oauth/logout",
+		DeviceAuthorizationEndpoint:       issuer + "/oauth/device_authorization",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ScopesSupported:                   config.AppConfig.OAuthServerScopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials, models.GrantTypeDeviceCode},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{utils.CodeChallengeMethodS256, utils.CodeChallengeMethodPlain},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp", "email", "email_verified", "name", "updated_at"},
+	}
+}
+
+// UserInfo returns the claims about the user released by the scope
+func (s *OAuthServerService) UserInfo(userID uint, scope string) (*UserInfo, error) {
+	user, err := s.activeUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserInfo{
+		Subject:        strconv.FormatUint(uint64(user.ID), 10),
+		OIDCUserClaims: oidcUserClaims(user, scope),
+	}, nil
+}
+
+// ValidateLogout checks an RP-initiated logout request. It returns the user
+// the ID token hint was issued to, if one was sent, and where to send the
+// browser after signing out, if anywhere.
+func (s *OAuthServerService) ValidateLogout(req *LogoutRequest) (uint, string, error) {
+	var userID uint
+	if req.IDTokenHint != "" {
+		// The hint is usually expired by the time the user signs out
+		claims := &utils.IDTokenClaims{}
+		if err := utils.ParseClaims(req.IDTokenHint, claims, jwt.WithoutClaimsValidation()); err != nil ||
+			claims.Issuer != config.AppConfig.OAuthIssuer || len(claims.Audience) == 0 {
+			return 0, "", oauthError(ErrInvalidOAuthRequest, "Invalid id_token_hint")
+		}
+		if req.ClientID == "" {
+			req.ClientID = claims.Audience[0]
+		} else if !slices.Contains(claims.Audience, req.ClientID) {
+			return 0, "", oauthError(ErrInvalidOAuthRequest, "id_token_hint was not issued to this client")
+		}
+
+		id, err := strconv.ParseUint(claims.Subject, 10, 64)
+		if err != nil {
+			return 0, "", oauthError(ErrInvalidOAuthRequest, "Invalid id_token_hint")
+		}
+		userID = uint(id)
+	}
+
+	if req.PostLogoutRedirectURI == "" {
+		return userID, "", nil
+	}
+	if req.ClientID == "" {
+		return 0, "", oauthError(ErrInvalidOAuthRequest, "client_id or id_token_hint is required with post_logout_redirect_uri")
+	}
+
+	client, err := s.clientService.Get(req.ClientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return 0, "", oauthError(ErrInvalidClient, "Unknown client")
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if !slices.Contains(client.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+		return 0, "", ErrInvalidClientRedirect
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return userID, withQuery(req.PostLogoutRedirectURI, params), nil
+}
+
+// idToken signs the ID token of a grant
+func (s *OAuthServerService) idToken(grant oauthGrant, scope string) (string, error) {
+	claims := utils.IDTokenClaims{
+		Nonce:           grant.nonce,
+		AuthorizedParty: grant.client.ClientID,
+		OIDCUserClaims:  oidcUserClaims(grant.user, scope),
+	}
+	claims.Issuer = config.AppConfig.OAuthIssuer
+	claims.Subject = strconv.FormatUint(uint64(grant.user.ID), 10)
+	claims.Audience = jwt.ClaimStrings{grant.client.ClientID}
+	if !grant.authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(grant.authTime)
+	}
+
+	return utils.GenerateIDToken(claims, utils.AccessTokenTTL())
+}
+
+// oidcUserClaims returns the standard claims released by the email and
+// profile scopes
+func oidcUserClaims(user *models.User, scope string) utils.OIDCUserClaims {
+	var claims utils.OIDCUserClaims
+	if hasScope(scope, "email") {
+		verified := user.IsEmailVerified
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	if hasScope(scope, "profile") {
+		claims.Name = user.Name
+		claims.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	return claims
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// exchangeTestCode runs the authorization code flow with the scope and nonce
+// and returns the token response
+func exchangeTestCode(t *testing.T, service *OAuthServerService, client *models.OAuthClient, user *models.User, scope, nonce string) *OAuthTokenResponse {
+	t.Helper()
+
+	code := authorizeCode(t, service, user, &AuthorizationRequest{
+		Scope:               scope,
+		Nonce:               nonce,
+		CodeChallenge:       utils.CodeChallengeS256(testCodeVerifier),
+		CodeChallengeMethod: "S256",
+	})
+	response, err := service.Token(client, &TokenRequest{
+		GrantType:    models.GrantTypeAuthorizationCode,
+		Code:         code,
+		CodeVerifier: testCodeVerifier,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestIDTokenClaims(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+
+	response := exchangeTestCode(t, service, client, user, "openid email", "n-0S6_WzA2Mj")
+	if response.IDToken == "" {
+		t.Fatal("no ID token for the openid scope")
+	}
+
+	claims := &utils.IDTokenClaims{}
+	if err := utils.ParseClaims(response.IDToken, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Issuer != config.AppConfig.OAuthIssuer {
+		t.Errorf("iss = %q, want %q", claims.Issuer, config.AppConfig.OAuthIssuer)
+	}
+	if claims.Subject != strconv.FormatUint(uint64(user.ID), 10) {
+		t.Errorf("sub = %q, want the user ID %d", claims.Subject, user.ID)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != client.ClientID || claims.AuthorizedParty != client.ClientID {
+		t.Errorf("aud = %v, azp = %q, want %q", claims.Audience, claims.AuthorizedParty, client.ClientID)
+	}
+	if claims.Nonce != "n-0S6_WzA2Mj" {
+		t.Errorf("nonce = %q, want the one from the authorization request", claims.Nonce)
+	}
+	if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) > time.Minute {
+		t.Errorf("auth_time = %v, want the time the user signed in", claims.AuthTime)
+	}
+
+	// Only the claims of the granted scopes are released
+	if claims.Email != user.Email || claims.EmailVerified == nil || !*claims.EmailVerified {
+		t.Errorf("email = %q, email_verified = %v, want %q, true", claims.Email, claims.EmailVerified, user.Email)
+	}
+	if claims.Name != "" {
+		t.Errorf("name = %q released without the profile scope", claims.Name)
+	}
+}
+
+func TestNoIDTokenWithoutOpenIDScope(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+
+	if response := exchangeTestCode(t, service, client, user, "email", ""); response.IDToken != "" {
+		t.Fatal("got an ID token without the openid scope")
+	}
+}
+
+func TestLogoutIDTokenHintMustMatchClient(t *testing.T) {
+	service, client, user := setupOAuthServerTest(t)
+	response := exchangeTestCode(t, service, client, user, "openid", "")
+
+	userID, _, err := service.ValidateLogout(&LogoutRequest{IDTokenHint: response.IDToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != user.ID {
+		t.Fatalf("got user %d from the hint, want %d", userID, user.ID)
+	}
+
+	_, _, err = service.ValidateLogout(&LogoutRequest{IDTokenHint: response.IDToken, ClientID: "other-client"})
+	if !errors.Is(err, ErrInvalidOAuthRequest) {
+		t.Fatalf("hint for another client: got %v, want %v", err, ErrInvalidOAuthRequest)
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidOneTimeToken  = errors.New("invalid_token")
+	ErrOneTimeTokenExpired  = errors.New("token_expired")
+	ErrCodeAttemptsExceeded = errors.New("code_attempts_exceeded")
+)
+
+// emailCodeDigits is the length of numeric codes sent by email
+const emailCodeDigits = 6
+
+type OneTimeTokenService struct{}
+
+// NewOneTimeTokenService creates a new one-time token service
+func NewOneTimeTokenService() *OneTimeTokenService {
+	return &OneTimeTokenService{}
+}
+
+// Issue creates a link token for the given purpose, replacing any token the
+// user already had for it, and returns the raw token to send to the user
+func (s *OneTimeTokenService) Issue(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.store(db, userID, purpose, utils.HashToken(rawToken), false, ttl); err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+// IssueCode creates a numeric code for the given purpose, replacing any token
+// the user already had for it, and returns the code to send to the user
+func (s *OneTimeTokenService) IssueCode(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%0*d", emailCodeDigits, n.Int64())
+
+	if err := s.store(db, userID, purpose, codeHash(userID, purpose, code), true, ttl); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Lookup returns an unused, unexpired link token without consuming it
+func (s *OneTimeTokenService) Lookup(db *gorm.DB, rawToken, purpose string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	if err := db.Where("token_hash = ? AND purpose = ? AND is_code = ?", utils.HashToken(rawToken), purpose, false).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOneTimeToken
+		}
+		return nil, err
+	}
+
+	if token.UsedAt != nil {
+		return nil, ErrInvalidOneTimeToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrOneTimeTokenExpired
+	}
+
+	return &token, nil
+}
+
+// Consume marks a link token as used and returns it. Each token can only be
+// consumed once, even by concurrent requests.
+func (s *OneTimeTokenService) Consume(db *gorm.DB, rawToken, purpose string) (*models.OneTimeToken, error) {
+	token, err := s.Lookup(db, rawToken, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.markUsed(db, token)
+}
+
+// ConsumeCode checks a numeric code sent to the user and marks it as used.
+// Wrong guesses are counted and the code stops working after too many.
+func (s *OneTimeTokenService) ConsumeCode(db *gorm.DB, userID uint, purpose, code string) (*models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	result := db.Where("user_id = ? AND purpose = ? AND is_code = ? AND used_at IS NULL", userID, purpose, true).
+		Order("id DESC").Limit(1).Find(&token)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrOneTimeTokenExpired
+	}
+	if token.Attempts >= config.AppConfig.EmailCodeMaxAttempts {
+		return nil, ErrCodeAttemptsExceeded
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.TokenHash), []byte(codeHash(userID, purpose, code))) != 1 {
+		// Counted outside db so a rolled back transaction keeps the attempt
+		if err := database.DB.Model(&models.OneTimeToken{}).Where("id = ?", token.ID).
+			Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			return nil, err
+		}
+		if token.Attempts+1 >= config.AppConfig.EmailCodeMaxAttempts {
+			return nil, ErrCodeAttemptsExceeded
+		}
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	return s.markUsed(db, &token)
+}
+
+// Purge deletes tokens that have expired or were used more than a day ago
+func (s *OneTimeTokenService) Purge() error {
+	return database.DB.
+		Where("expires_at <= ? OR used_at <= ?", time.Now(), time.Now().Add(-24*time.Hour)).
+		Delete(&models.OneTimeToken{}).Error
+}
+
+// store replaces the user's tokens for the purpose with a new one
+func (s *OneTimeTokenService) store(db *gorm.DB, userID uint, purpose, tokenHash string, isCode bool, ttl time.Duration) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ?", userID, purpose).
+			Delete(&models.OneTimeToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.OneTimeToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: tokenHash,
+			IsCode:    isCode,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+}
+
+// markUsed atomically marks a token as used
+func (s *OneTimeTokenService) markUsed(db *gorm.DB, token *models.OneTimeToken) (*models.OneTimeToken, error) {
+	now := time.Now()
+	result := db.Model(&models.OneTimeToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	token.UsedAt = &now
+	return token, nil
+}
+
+// codeHash scopes a code to its user and purpose, since the same short code
+// can be issued to many users at once
+func codeHash(userID uint, purpose, code string) string {
+	return utils.HashToken(fmt.Sprintf("code:%d:%s:%s", userID, purpose, code))
+}
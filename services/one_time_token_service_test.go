@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+func TestOneTimeTokenIsStoredHashedAndUsableOnce(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	user := createTestUser(t, "user@example.com")
+	service := NewOneTimeTokenService()
+
+	raw, err := service.Issue(database.DB, user.ID, models.TokenPurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored models.OneTimeToken
+	if err := database.DB.Where("user_id = ?", user.ID).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.TokenHash != utils.HashToken(raw) {
+		t.Fatalf("stored %q, want the SHA-256 hash of the token", stored.TokenHash)
+	}
+
+	if _, err := service.Consume(database.DB, raw, models.TokenPurposeEmailVerification); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming for another purpose: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+	if _, err := service.Consume(database.DB, stored.TokenHash, models.TokenPurposePasswordReset); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming the stored hash: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+
+	token, err := service.Consume(database.DB, raw, models.TokenPurposePasswordReset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UserID != user.ID || token.UsedAt == nil {
+		t.Fatalf("consumed token %+v", token)
+	}
+
+	if _, err := service.Consume(database.DB, raw, models.TokenPurposePasswordReset); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming twice: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+}
+
+func TestOneTimeTokenReissueReplacesEarlierToken(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	user := createTestUser(t, "user@example.com")
+	service := NewOneTimeTokenService()
+
+	first, err := service.Issue(database.DB, user.ID, models.TokenPurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.Issue(database.DB, user.ID, models.TokenPurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Consume(database.DB, first, models.TokenPurposePasswordReset); !errors.Is(err, ErrInvalidOneTimeToken) {
+		t.Fatalf("consuming the replaced token: got %v, want %v", err, ErrInvalidOneTimeToken)
+	}
+	if _, err := service.Consume(database.DB, second, models.TokenPurposePasswordReset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOneTimeTokenExpiry(t *testing.T) {
+	setupTestDB(t, &models.OneTimeToken{})
+	user := createTestUser(t, "user@example.com")
+	service := NewOneTimeTokenService()
+
+	raw, err := service.Issue(database.DB, user.ID, models.TokenPurposeEmailVerification, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Consume(database.DB, raw, models.TokenPurposeEmailVerification); !errors.Is(err, ErrOneTimeTokenExpired) {
+		t.Fatalf("consuming an expired token: got %v, want %v", err, ErrOneTimeTokenExpired)
+	}
+
+	if err := service.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	database.DB.Model(&models.OneTimeToken{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("purge left %d expired tokens", count)
+	}
+}
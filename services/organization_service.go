@@ -0,0 +1,427 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound    = errors.New("organization_not_found")
+	ErrOrganizationSlugTaken   = errors.New("organization_slug_taken")
+	ErrInvalidOrganizationSlug = errors.New("invalid_organization_slug")
+	ErrMembershipNotFound      = errors.New("membership_not_found")
+	ErrAlreadyMember           = errors.New("already_member")
+	ErrInvalidOrgRole          = errors.New("invalid_organization_role")
+	ErrOrgRoleNotAllowed       = errors.New("organization_role_not_allowed")
+	ErrLastOwner               = errors.New("last_owner")
+	ErrInvitationNotFound      = errors.New("invitation_not_found")
+	ErrInvitationEmailMismatch = errors.New("invitation_email_mismatch")
+)
+
+// organizationSlugPattern allows lowercase words separated by single hyphens
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type OrganizationService struct {
+	emailService *EmailService
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService() *OrganizationService {
+	return &OrganizationService{
+		emailService: NewEmailService(),
+	}
+}
+
+// Create adds an organization with the user as its owner
+func (s *OrganizationService) Create(userID uint, name, slug string) (*models.Organization, error) {
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Organization{}).Where("slug = ?", slug).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrOrganizationSlugTaken
+	}
+
+	organization := models.Organization{Name: name, Slug: slug}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMembership{
+			OrganizationID: organization.ID,
+			UserID:         userID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+// ListForUser returns the user's memberships with their organizations
+func (s *OrganizationService) ListForUser(userID uint) ([]models.OrganizationMembership, error) {
+	var memberships []models.OrganizationMembership
+	err := database.DB.Preload("Organization").
+		Where("user_id = ?", userID).
+		Order("organization_id").
+		Find(&memberships).Error
+	return memberships, err
+}
+
+// Get returns a single organization
+func (s *OrganizationService) Get(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	if err := database.DB.First(&organization, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// Update renames an organization. The slug cannot change.
+func (s *OrganizationService) Update(id uint, name string) (*models.Organization, error) {
+	organization, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	organization.Name = name
+	if err := database.DB.Save(organization).Error; err != nil {
+		return nil, err
+	}
+
+	return organization, nil
+}
+
+// Delete removes an organization with its memberships and invitations.
+// Sessions working in it fall back to having no active organization.
+func (s *OrganizationService) Delete(id uint) error {
+	organization, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).Where("organization_id = ?", organization.ID).
+			Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.OrganizationMembership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", organization.ID).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(organization).Error
+	})
+}
+
+// Membership returns the user's membership in an organization
+func (s *OrganizationService) Membership(organizationID, userID uint) (*models.OrganizationMembership, error) {
+	return s.membership(database.DB, organizationID, userID)
+}
+
+// SessionMembership returns the user's membership in the session's active
+// organization, or nil when there is none or the user has since left it
+func (s *OrganizationService) SessionMembership(db *gorm.DB, sessionID string) (*models.OrganizationMembership, error) {
+	var session models.Session
+	if err := db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	if session.OrganizationID == nil {
+		return nil, nil
+	}
+
+	membership, err := s.membership(db, *session.OrganizationID, session.UserID)
+	if errors.Is(err, ErrMembershipNotFound) {
+		return nil, nil
+	}
+	return membership, err
+}
+
+// ListMembers returns an organization's members with their users
+func (s *OrganizationService) ListMembers(organizationID uint, pagination *utils.Pagination) ([]models.OrganizationMembership, error) {
+	query := database.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ?", organizationID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	pagination.SetTotal(total)
+
+	var memberships []models.OrganizationMembership
+	err := query.Preload("User").
+		Order("id").
+		Offset(pagination.Offset()).
+		Limit(pagination.PerPage).
+		Find(&memberships).Error
+	return memberships, err
+}
+
+// Invite offers a role in the organization to an email address and returns
+// the invitation with its token, which is only available now. Whether anyone
+// is registered with the email makes no difference, so invitations cannot be
+// used to find out who has an account. actorRole is the role of the member
+// sending the invitation.
+func (s *OrganizationService) Invite(organizationID, inviterID uint, actorRole, email, role string) (*models.OrganizationInvitation, string, error) {
+	if !models.IsValidOrgRole(role) {
+		return nil, "", ErrInvalidOrgRole
+	}
+	if !canManageMember(actorRole, "", role) {
+		return nil, "", ErrOrgRoleNotAllowed
+	}
+
+	if _, err := s.Get(organizationID); err != nil {
+		return nil, "", err
+	}
+
+	// Members are visible to the organization already, so this reveals nothing
+	var members int64
+	if err := database.DB.Model(&models.OrganizationMembership{}).
+		Where("organization_id = ? AND user_id IN (?)", organizationID,
+			database.DB.Model(&models.User{}).Select("id").Where("email = ?", email)).
+		Count(&members).Error; err != nil {
+		return nil, "", err
+	}
+	if members > 0 {
+		return nil, "", ErrAlreadyMember
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	invitation := models.OrganizationInvitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      utils.HashToken(token),
+		InvitedByID:    inviterID,
+		ExpiresAt:      time.Now().Add(time.Duration(config.AppConfig.OrgInvitationDays) * 24 * time.Hour),
+	}
+
+	// Inviting the same email again replaces the earlier invitation
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND email = ?", organizationID, email).
+			Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &invitation, token, nil
+}
+
+// SendInvitation emails the invitee a link to accept the invitation in the
+// background
+func (s *OrganizationService) SendInvitation(invitation *models.OrganizationInvitation, token string) {
+	go func() {
+		organization, err := s.Get(invitation.OrganizationID)
+		if err == nil {
+			err = s.emailService.SendOrganizationInvitationEmail(invitation.Email, organization.Name, invitation.Role, token)
+		}
+		if err != nil {
+			log.Printf("Failed to send organization invitation email: %v", err)
+		}
+	}()
+}
+
+// ListInvitations returns the organization's pending invitations
+func (s *OrganizationService) ListInvitations(organizationID uint) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := database.DB.Where("organization_id = ? AND expires_at > ?", organizationID, time.Now()).
+		Order("id").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// RevokeInvitation withdraws a pending invitation
+func (s *OrganizationService) RevokeInvitation(organizationID, id uint) error {
+	result := database.DB.Where("id = ? AND organization_id = ?", id, organizationID).Delete(&models.OrganizationInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// AcceptInvitation makes the user a member with the role they were invited
+// to. Only the user the invitation was emailed to can accept it.
+func (s *OrganizationService) AcceptInvitation(user *models.User, token string) (*models.OrganizationMembership, error) {
+	var membership models.OrganizationMembership
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.OrganizationInvitation
+		if err := tx.Where("token_hash = ?", utils.HashToken(token)).First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+		if invitation.IsExpired() {
+			return ErrInvitationNotFound
+		}
+		if !strings.EqualFold(invitation.Email, user.Email) {
+			return ErrInvitationEmailMismatch
+		}
+
+		if _, err := s.membership(tx, invitation.OrganizationID, user.ID); err == nil {
+			return ErrAlreadyMember
+		} else if !errors.Is(err, ErrMembershipNotFound) {
+			return err
+		}
+
+		// Deleting first makes sure a concurrent request cannot use it as well
+		result := tx.Delete(&invitation)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotFound
+		}
+
+		var organization models.Organization
+		if err := tx.First(&organization, invitation.OrganizationID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+
+		membership = models.OrganizationMembership{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         user.ID,
+			Role:           invitation.Role,
+			Organization:   &organization,
+		}
+		return tx.Omit("Organization").Create(&membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// PurgeInvitations deletes invitations that have expired
+func (s *OrganizationService) PurgeInvitations() error {
+	return database.DB.Where("expires_at <= ?", time.Now()).Delete(&models.OrganizationInvitation{}).Error
+}
+
+// UpdateMember changes a member's role. Only owners can grant or take away
+// the owner role, and the last owner cannot step down.
+func (s *OrganizationService) UpdateMember(organizationID uint, actorRole string, userID uint, role string) (*models.OrganizationMembership, error) {
+	if !models.IsValidOrgRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+
+	membership, err := s.Membership(organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageMember(actorRole, membership.Role, role) {
+		return nil, ErrOrgRoleNotAllowed
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if membership.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			if err := s.ensureOtherOwner(tx, organizationID, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(membership).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// RemoveMember takes a user out of the organization. Any member may leave;
+// removing someone else needs a role that can manage theirs.
+func (s *OrganizationService) RemoveMember(organizationID, actorID uint, actorRole string, userID uint) error {
+	membership, err := s.Membership(organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if userID != actorID && !canManageMember(actorRole, membership.Role, "") {
+		return ErrOrgRoleNotAllowed
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if membership.Role == models.OrgRoleOwner {
+			if err := s.ensureOtherOwner(tx, organizationID, userID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Session{}).Where("user_id = ? AND organization_id = ?", userID, organizationID).
+			Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(membership).Error
+	})
+}
+
+// membership looks up a user's membership in an organization
+func (s *OrganizationService) membership(db *gorm.DB, organizationID, userID uint) (*models.OrganizationMembership, error) {
+	var membership models.OrganizationMembership
+	if err := db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMembershipNotFound
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// ensureOtherOwner fails when the user is the organization's only owner
+func (s *OrganizationService) ensureOtherOwner(db *gorm.DB, organizationID, userID uint) error {
+	var owners int64
+	if err := db.Model(&models.OrganizationMembership{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", organizationID, models.OrgRoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// canManageMember reports whether a member with actorRole may change a
+// membership from currentRole to newRole. An empty role stands for no
+// membership. Owners manage everyone; admins manage everyone but owners.
+func canManageMember(actorRole, currentRole, newRole string) bool {
+	switch actorRole {
+	case models.OrgRoleOwner:
+		return true
+	case models.OrgRoleAdmin:
+		return currentRole != models.OrgRoleOwner && newRole != models.OrgRoleOwner
+	default:
+		return false
+	}
+}
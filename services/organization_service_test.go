@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+// setupOrganizationTest creates an organization owned by a fresh user
+func setupOrganizationTest(t *testing.T) (*OrganizationService, *models.Organization, *models.User) {
+	t.Helper()
+
+	setupTestDB(t, &models.Session{}, &models.Organization{}, &models.OrganizationMembership{}, &models.OrganizationInvitation{})
+
+	owner := createTestUser(t, "owner@example.com")
+	service := NewOrganizationService()
+	organization, err := service.Create(owner.ID, "Acme", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, organization, owner
+}
+
+func TestInviteDoesNotRevealRegisteredEmails(t *testing.T) {
+	service, organization, owner := setupOrganizationTest(t)
+	createTestUser(t, "registered@example.com")
+
+	for _, email := range []string{"registered@example.com", "unknown@example.com"} {
+		invitation, token, err := service.Invite(organization.ID, owner.ID, models.OrgRoleOwner, email, models.OrgRoleMember)
+		if err != nil {
+			t.Fatalf("inviting %s: %v", email, err)
+		}
+		if invitation.Email != email || token == "" {
+			t.Fatalf("inviting %s returned %+v", email, invitation)
+		}
+	}
+
+	var members int64
+	database.DB.Model(&models.OrganizationMembership{}).Where("organization_id = ?", organization.ID).Count(&members)
+	if members != 1 {
+		t.Fatalf("invitations added members directly: %d members", members)
+	}
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	service, organization, owner := setupOrganizationTest(t)
+	invitee := createTestUser(t, "invitee@example.com")
+	other := createTestUser(t, "other@example.com")
+
+	_, token, err := service.Invite(organization.ID, owner.ID, models.OrgRoleOwner, invitee.Email, models.OrgRoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.AcceptInvitation(other, token); !errors.Is(err, ErrInvitationEmailMismatch) {
+		t.Fatalf("accepting someone else's invitation: got %v, want %v", err, ErrInvitationEmailMismatch)
+	}
+
+	membership, err := service.AcceptInvitation(invitee, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if membership.OrganizationID != organization.ID || membership.Role != models.OrgRoleAdmin {
+		t.Fatalf("accepting gave %+v", membership)
+	}
+
+	if _, err := service.AcceptInvitation(invitee, token); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("accepting twice: got %v, want %v", err, ErrInvitationNotFound)
+	}
+}
+
+func TestAcceptExpiredInvitation(t *testing.T) {
+	service, organization, owner := setupOrganizationTest(t)
+	invitee := createTestUser(t, "invitee@example.com")
+
+	invitation, token, err := service.Invite(organization.ID, owner.ID, models.OrgRoleOwner, invitee.Email, models.OrgRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(invitation).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.AcceptInvitation(invitee, token); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("accepting an expired invitation: got %v, want %v", err, ErrInvitationNotFound)
+	}
+	if _, err := service.Membership(organization.ID, invitee.ID); !errors.Is(err, ErrMembershipNotFound) {
+		t.Fatalf("expired invitation added a membership: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryService struct{}
+
+// NewPasswordHistoryService creates a new password history service
+func NewPasswordHistoryService() *PasswordHistoryService {
+	return &PasswordHistoryService{}
+}
+
+// CheckReuse rejects a password matching the user's current password or one
+// of their remembered previous passwords with a *PasswordPolicyError
+func (s *PasswordHistoryService) CheckReuse(db *gorm.DB, user *models.User, password string) error {
+	size := config.AppConfig.PasswordHistorySize
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+
+	var history []models.PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).Order("id DESC").Limit(size).Find(&history).Error; err != nil {
+		return err
+	}
+	for _, entry := range history {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+
+	for _, hash := range hashes {
+		if ok, _ := utils.VerifyPassword(password, hash); ok {
+			return &PasswordPolicyError{Violations: []PasswordViolation{{
+				Rule:    PasswordRuleHistory,
+				Message: "Password must not match a recently used password",
+			}}}
+		}
+	}
+
+	return nil
+}
+
+// Record remembers the hash of a password that is being replaced and prunes
+// entries beyond the configured history size
+func (s *PasswordHistoryService) Record(db *gorm.DB, userID uint, previousHash string) error {
+	size := config.AppConfig.PasswordHistorySize
+	if size <= 0 || previousHash == "" {
+		return nil
+	}
+
+	if err := db.Create(&models.PasswordHistory{UserID: userID, PasswordHash: previousHash}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	if err := db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(size).
+		Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ? AND id NOT IN ?", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
+
+// Clear forgets the user's previous passwords
+func (s *PasswordHistoryService) Clear(db *gorm.DB, userID uint) error {
+	return db.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error
+}
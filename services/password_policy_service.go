@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// Password policy rules reported in violations
+const (
+	PasswordRuleMinLength      = "min_length"
+	PasswordRuleMaxLength      = "max_length"
+	PasswordRuleUppercase      = "uppercase"
+	PasswordRuleLowercase      = "lowercase"
+	PasswordRuleDigit          = "digit"
+	PasswordRuleSymbol         = "symbol"
+	PasswordRulePersonalInfo   = "personal_info"
+	PasswordRuleCommonPassword = "common_password"
+	PasswordRuleEntropy        = "entropy"
+	PasswordRuleHistory        = "history"
+)
+
+// minPersonalInfoLength ignores name parts too short to be meaningful
+const minPersonalInfoLength = 3
+
+// PasswordViolation describes a single password policy rule that failed
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password failed
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "weak_password"
+}
+
+// passwordBlocklist holds lowercased common passwords
+var passwordBlocklist = map[string]struct{}{}
+
+// LoadPasswordBlocklist reads the common password list, one password per
+// line. Blank lines and lines starting with # are ignored. When the file
+// cannot be found the built-in list is used instead.
+func LoadPasswordBlocklist() error {
+	path := config.AppConfig.PasswordBlocklistFile
+	if path == "" {
+		passwordBlocklist = map[string]struct{}{}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Warning: password blocklist %s not found, using the built-in list", path)
+		blocklist, err := parsePasswordBlocklist(strings.NewReader(config.DefaultPasswordBlocklist))
+		if err != nil {
+			return err
+		}
+		passwordBlocklist = blocklist
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	blocklist, err := parsePasswordBlocklist(file)
+	if err != nil {
+		return err
+	}
+
+	passwordBlocklist = blocklist
+	return nil
+}
+
+// parsePasswordBlocklist reads lowercased passwords from a blocklist
+func parsePasswordBlocklist(r io.Reader) (map[string]struct{}, error) {
+	blocklist := map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return blocklist, scanner.Err()
+}
+
+type PasswordPolicyService struct{}
+
+// NewPasswordPolicyService creates a new password policy service
+func NewPasswordPolicyService() *PasswordPolicyService {
+	return &PasswordPolicyService{}
+}
+
+// Validate checks a password against the configured policy. user supplies the
+// email and name the password must not contain and may be nil. It returns a
+// *PasswordPolicyError listing every failed rule.
+func (s *PasswordPolicyService) Validate(password string, user *models.User) error {
+	cfg := config.AppConfig
+	var violations []PasswordViolation
+
+	length := len([]rune(password))
+	if length < cfg.PasswordMinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", cfg.PasswordMinLength),
+		})
+	}
+	if cfg.PasswordMaxLength > 0 && length > cfg.PasswordMaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d characters long", cfg.PasswordMaxLength),
+		})
+	} else if maxBytes := utils.MaxPasswordBytes(); maxBytes > 0 && len(password) > maxBytes {
+		// bcrypt limits bytes rather than characters
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", maxBytes),
+		})
+	}
+
+	classes := passwordCharacterClasses(password)
+	if cfg.PasswordRequireUppercase && !classes.upper {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleUppercase, Message: "Password must contain an uppercase letter"})
+	}
+	if cfg.PasswordRequireLowercase && !classes.lower {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleLowercase, Message: "Password must contain a lowercase letter"})
+	}
+	if cfg.PasswordRequireDigit && !classes.digit {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleDigit, Message: "Password must contain a digit"})
+	}
+	if cfg.PasswordRequireSymbol && !classes.symbol {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleSymbol, Message: "Password must contain a symbol"})
+	}
+
+	if cfg.PasswordDisallowPersonalInfo && user != nil && containsPersonalInfo(password, user) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRulePersonalInfo, Message: "Password must not contain your email or name"})
+	}
+
+	if _, blocked := passwordBlocklist[strings.ToLower(password)]; blocked {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleCommonPassword, Message: "Password is too common"})
+	}
+
+	if cfg.PasswordMinEntropyBits > 0 && passwordEntropy(password) < float64(cfg.PasswordMinEntropyBits) {
+		violations = append(violations, PasswordViolation{Rule: PasswordRuleEntropy, Message: "Password is too easy to guess"})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+type characterClasses struct {
+	upper, lower, digit, symbol, other bool
+}
+
+func passwordCharacterClasses(password string) characterClasses {
+	var classes characterClasses
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			classes.other = true
+		case unicode.IsUpper(r):
+			classes.upper = true
+		case unicode.IsLower(r):
+			classes.lower = true
+		case unicode.IsDigit(r):
+			classes.digit = true
+		default:
+			classes.symbol = true
+		}
+	}
+	return classes
+}
+
+// passwordEntropy roughly estimates entropy in bits from the size of the
+// character pool used. Repeated characters and runs such as "abc" or "321"
+// are only counted once.
+func passwordEntropy(password string) float64 {
+	classes := passwordCharacterClasses(password)
+
+	pool := 0
+	if classes.lower {
+		pool += 26
+	}
+	if classes.upper {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.symbol {
+		pool += 33
+	}
+	if classes.other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	effectiveLength := 0
+	var prev rune
+	for i, r := range []rune(password) {
+		diff := r - prev
+		if i == 0 || diff < -1 || diff > 1 {
+			effectiveLength++
+		}
+		prev = r
+	}
+
+	return float64(effectiveLength) * math.Log2(float64(pool))
+}
+
+// containsPersonalInfo reports whether the password contains the user's
+// email local part or any part of their name
+func containsPersonalInfo(password string, user *models.User) bool {
+	lower := strings.ToLower(password)
+
+	parts := strings.Fields(strings.ToLower(user.Name))
+	if local, _, found := strings.Cut(strings.ToLower(user.Email), "@"); found {
+		parts = append(parts, local)
+	}
+
+	for _, part := range parts {
+		if len([]rune(part)) >= minPersonalInfoLength && strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
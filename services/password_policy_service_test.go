@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// policyViolations returns the rules a password fails
+func policyViolations(t *testing.T, password string) []string {
+	t.Helper()
+
+	err := NewPasswordPolicyService().Validate(password, nil)
+	if err == nil {
+		return nil
+	}
+
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, violation := range policyErr.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyLimitsBcryptPasswordBytes(t *testing.T) {
+	config.LoadConfig()
+	t.Cleanup(func() {
+		config.LoadConfig()
+		utils.LoadPasswordHasher()
+	})
+
+	// 80 characters are within PASSWORD_MAX_LENGTH but take 100 bytes,
+	// over bcrypt's 72 byte limit
+	password := strings.Repeat("Zé9!", 20)
+
+	if err := utils.LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if rules := policyViolations(t, password); slices.Contains(rules, PasswordRuleMaxLength) {
+		t.Errorf("argon2id policy rejected a %d byte password: %v", len(password), rules)
+	}
+
+	config.AppConfig.PasswordHashAlgorithm = "bcrypt"
+	config.AppConfig.BcryptCost = 4
+	if err := utils.LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if rules := policyViolations(t, password); !slices.Contains(rules, PasswordRuleMaxLength) {
+		t.Errorf("bcrypt policy accepted a %d byte password: %v", len(password), rules)
+	}
+	if rules := policyViolations(t, strings.Repeat("Zé9!", 14)+"Ab"); slices.Contains(rules, PasswordRuleMaxLength) {
+		t.Errorf("bcrypt policy rejected a 72 byte password: %v", rules)
+	}
+}
+
+func TestLoadPasswordBlocklistFallsBackToBuiltInList(t *testing.T) {
+	config.LoadConfig()
+	t.Cleanup(func() {
+		config.LoadConfig()
+		LoadPasswordBlocklist()
+	})
+
+	config.AppConfig.PasswordBlocklistFile = filepath.Join(t.TempDir(), "missing.txt")
+	if err := LoadPasswordBlocklist(); err != nil {
+		t.Fatalf("LoadPasswordBlocklist() error = %v", err)
+	}
+	if rules := policyViolations(t, "password123"); !slices.Contains(rules, PasswordRuleCommonPassword) {
+		t.Errorf("built-in blocklist did not reject a common password: %v", rules)
+	}
+
+	config.AppConfig.PasswordBlocklistFile = ""
+	if err := LoadPasswordBlocklist(); err != nil {
+		t.Fatalf("LoadPasswordBlocklist() error = %v", err)
+	}
+	if rules := policyViolations(t, "password123"); slices.Contains(rules, PasswordRuleCommonPassword) {
+		t.Errorf("disabled blocklist rejected a password: %v", rules)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so they can be told
+// apart from JWTs in the Authorization header
+const PersonalAccessTokenPrefix = "pat_"
+
+var (
+	ErrPersonalAccessTokenNotFound = errors.New("personal_access_token_not_found")
+	ErrInvalidPersonalAccessToken  = errors.New("invalid_personal_access_token")
+	ErrInvalidTokenScope           = errors.New("invalid_token_scope")
+	ErrInvalidTokenExpiry          = errors.New("invalid_token_expiry")
+)
+
+// PersonalAccessTokenInput holds the settings of a new personal access token
+type PersonalAccessTokenInput struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+type PersonalAccessTokenService struct {
+	roleService  *RoleService
+	tokenService *TokenService
+}
+
+// NewPersonalAccessTokenService creates a new personal access token service
+func NewPersonalAccessTokenService() *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		roleService:  NewRoleService(),
+		tokenService: NewTokenService(),
+	}
+}
+
+// Create issues a personal access token and returns it with its secret,
+// which is only available now. Tokens may only carry permissions the user's
+// roles currently grant.
+func (s *PersonalAccessTokenService) Create(userID uint, input PersonalAccessTokenInput) (*models.PersonalAccessToken, string, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidTokenExpiry
+	}
+
+	scopes := slices.Compact(slices.Sorted(slices.Values(input.Scopes)))
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidTokenScope
+	}
+
+	permissions := slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+		return scope == models.ScopeProfileRead || scope == models.ScopeProfileWrite
+	})
+	if len(permissions) > 0 {
+		roles, err := s.roleService.RoleNames(database.DB, userID)
+		if err != nil {
+			return nil, "", err
+		}
+		allowed, err := s.roleService.HasPermissions(roles, permissions)
+		if err != nil {
+			return nil, "", err
+		}
+		if !allowed {
+			return nil, "", ErrInvalidTokenScope
+		}
+	}
+
+	tokenVersion, err := s.tokenService.TokenVersion(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	random, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := PersonalAccessTokenPrefix + random
+
+	token := models.PersonalAccessToken{
+		UserID:       userID,
+		Name:         input.Name,
+		TokenHash:    utils.HashToken(secret),
+		Prefix:       secret[:len(PersonalAccessTokenPrefix)+8],
+		Scopes:       scopes,
+		TokenVersion: tokenVersion,
+		ExpiresAt:    input.ExpiresAt,
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &token, secret, nil
+}
+
+// List returns the user's personal access tokens, newest first
+func (s *PersonalAccessTokenService) List(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := database.DB.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// Delete revokes one of the user's personal access tokens
+func (s *PersonalAccessTokenService) Delete(userID, id uint) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// Authenticate validates a personal access token, records its use and
+// returns it with its owner and the owner's current roles
+func (s *PersonalAccessTokenService) Authenticate(secret, ipAddress string) (*models.PersonalAccessToken, *models.User, []string, error) {
+	var token models.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(secret)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, nil, nil, err
+	}
+	if token.IsExpired() {
+		return nil, nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, token.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, nil, nil, err
+	}
+	if user.IsDisabled() || user.TokenVersion != token.TokenVersion {
+		return nil, nil, nil, ErrInvalidPersonalAccessToken
+	}
+
+	roles, err := s.roleService.RoleNames(database.DB, user.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > lastSeenInterval || token.LastUsedIP != ipAddress {
+		now := time.Now()
+		token.LastUsedAt = &now
+		token.LastUsedIP = ipAddress
+		if err := database.DB.Model(&token).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ipAddress,
+		}).Error; err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return &token, &user, roles, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+func TestPersonalAccessTokenInvalidatedWithUserTokens(t *testing.T) {
+	setupTestDB(t, &models.Role{}, &models.PersonalAccessToken{}, &models.Session{}, &models.RefreshToken{})
+	user := createTestUser(t, "pat@example.com")
+	service := NewPersonalAccessTokenService()
+
+	_, secret, err := service.Create(user.ID, PersonalAccessTokenInput{Name: "Script", Scopes: []string{models.ScopeProfileRead}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, _, _, err := service.Authenticate(secret, "192.0.2.1"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	// A password reset or admin action invalidates every token the user has
+	if _, err := NewTokenService().InvalidateUserTokens(user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := service.Authenticate(secret, "192.0.2.1"); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+		t.Errorf("Authenticate() error = %v, want %v", err, ErrInvalidPersonalAccessToken)
+	}
+
+	_, secret, err = service.Create(user.ID, PersonalAccessTokenInput{Name: "Script", Scopes: []string{models.ScopeProfileRead}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, _, _, err := service.Authenticate(secret, "192.0.2.1"); err != nil {
+		t.Errorf("Authenticate() of a token created afterwards error = %v", err)
+	}
+}
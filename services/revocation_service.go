@@ -0,0 +1,108 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationStore keeps track of revoked access tokens. The database is the
+// source of truth; revoked IDs are cached in memory until they expire.
+type RevocationStore struct {
+	mu    sync.RWMutex
+	cache map[string]time.Time
+}
+
+// Revocations is the application-wide revocation store
+var Revocations = NewRevocationStore()
+
+// NewRevocationStore creates an empty revocation store
+func NewRevocationStore() *RevocationStore {
+	return &RevocationStore{
+		cache: make(map[string]time.Time),
+	}
+}
+
+// Load warms the cache with every unexpired revocation from the database
+func (s *RevocationStore) Load() error {
+	var revoked []models.RevokedToken
+	if err := database.DB.Where("expires_at > ?", time.Now()).Find(&revoked).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range revoked {
+		s.cache[token.JTI] = token.ExpiresAt
+	}
+
+	return nil
+}
+
+// Revoke marks the token with the given jti as revoked until it expires
+func (s *RevocationStore) Revoke(jti string, userID uint, expiresAt time.Time) error {
+	revoked := models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cache[jti] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked reports whether the token with the given jti has been revoked
+func (s *RevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.RLock()
+	expiresAt, found := s.cache[jti]
+	s.mu.RUnlock()
+
+	if found {
+		return time.Now().Before(expiresAt), nil
+	}
+
+	// Another instance may have revoked the token, so fall back to the database
+	var revoked models.RevokedToken
+	result := database.DB.Where("jti = ? AND expires_at > ?", jti, time.Now()).Limit(1).Find(&revoked)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	s.cache[jti] = revoked.ExpiresAt
+	s.mu.Unlock()
+
+	return true, nil
+}
+
+// Purge removes revocations whose tokens have already expired
+func (s *RevocationStore) Purge() error {
+	now := time.Now()
+
+	if err := database.DB.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for jti, expiresAt := range s.cache {
+		if !now.Before(expiresAt) {
+			delete(s.cache, jti)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownRole is returned when assigning a role that does not exist
+var ErrUnknownRole = errors.New("unknown_role")
+
+type RoleService struct{}
+
+// NewRoleService creates a new role service
+func NewRoleService() *RoleService {
+	return &RoleService{}
+}
+
+// AssignDefaultRoles grants a newly created user the default role, and the
+// admin role when their email is verified and listed in ADMIN_EMAILS
+func (s *RoleService) AssignDefaultRoles(db *gorm.DB, user *models.User) error {
+	if err := s.AssignRoles(db, user, []string{models.RoleUser}); err != nil {
+		return err
+	}
+
+	return s.AssignConfiguredAdmin(db, user)
+}
+
+// AssignConfiguredAdmin grants the admin role to a user whose email is listed
+// in ADMIN_EMAILS once that email is verified. Unverified accounts are never
+// promoted, since anyone could have registered them.
+func (s *RoleService) AssignConfiguredAdmin(db *gorm.DB, user *models.User) error {
+	if !user.IsEmailVerified {
+		return nil
+	}
+
+	for _, email := range config.AppConfig.AdminEmails {
+		if strings.EqualFold(email, user.Email) {
+			return s.AssignRoles(db, user, []string{models.RoleAdmin})
+		}
+	}
+
+	return nil
+}
+
+// AssignRoles adds the named roles to the user
+func (s *RoleService) AssignRoles(db *gorm.DB, user *models.User, names []string) error {
+	var roles []models.Role
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return err
+	}
+
+	return db.Model(user).Association("Roles").Append(&roles)
+}
+
+// SetRoles replaces the user's roles with the named roles
+func (s *RoleService) SetRoles(db *gorm.DB, user *models.User, names []string) error {
+	var roles []models.Role
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(roles, func(role models.Role) bool { return role.Name == name }) {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, name)
+		}
+	}
+
+	return db.Model(user).Association("Roles").Replace(&roles)
+}
+
+// RoleNames returns the names of the roles assigned to a user
+func (s *RoleService) RoleNames(db *gorm.DB, userID uint) ([]string, error) {
+	var names []string
+	err := db.Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+// HasPermissions reports whether the given roles together grant every
+// one of the permissions
+func (s *RoleService) HasPermissions(roles []string, permissions []string) (bool, error) {
+	if len(permissions) == 0 {
+		return true, nil
+	}
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	var granted []string
+	err := database.DB.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name IN ? AND permissions.name IN ?", roles, permissions).
+		Distinct().
+		Pluck("permissions.name", &granted).Error
+	if err != nil {
+		return false, err
+	}
+
+	grantedSet := make(map[string]bool, len(granted))
+	for _, name := range granted {
+		grantedSet[name] = true
+	}
+	for _, permission := range permissions {
+		if !grantedSet[permission] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
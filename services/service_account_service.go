@@ -0,0 +1,350 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix marks API keys so leaked keys are easy to recognize
+const APIKeyPrefix = "sak_"
+
+var (
+	ErrServiceAccountNotFound     = errors.New("service_account_not_found")
+	ErrAPIKeyNotFound             = errors.New("api_key_not_found")
+	ErrInvalidAPIKey              = errors.New("invalid_api_key")
+	ErrInvalidServiceAccountGrant = errors.New("invalid_service_account_permissions")
+	ErrInvalidGracePeriod         = errors.New("invalid_grace_period")
+)
+
+// ServiceAccountInput holds the settings of a service account
+type ServiceAccountInput struct {
+	Name           string
+	Description    string
+	Permissions    []string
+	OrganizationID *uint
+}
+
+type ServiceAccountService struct {
+	roleService         *RoleService
+	organizationService *OrganizationService
+}
+
+// NewServiceAccountService creates a new service account service
+func NewServiceAccountService() *ServiceAccountService {
+	return &ServiceAccountService{
+		roleService:         NewRoleService(),
+		organizationService: NewOrganizationService(),
+	}
+}
+
+// Create adds a service account owned by the given admin and, optionally, an
+// organization. Admins can only grant permissions their own roles give them.
+func (s *ServiceAccountService) Create(ownerID uint, ownerRoles []string, input ServiceAccountInput) (*models.ServiceAccount, error) {
+	permissions, err := s.grantablePermissions(ownerRoles, input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOrganization(input.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	account := models.ServiceAccount{
+		Name:           input.Name,
+		Description:    input.Description,
+		OwnerID:        ownerID,
+		OrganizationID: input.OrganizationID,
+		Permissions:    permissions,
+	}
+	if err := database.DB.Create(&account).Error; err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// List returns service accounts, newest first
+func (s *ServiceAccountService) List(pagination *utils.Pagination) ([]models.ServiceAccount, error) {
+	var total int64
+	if err := database.DB.Model(&models.ServiceAccount{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	pagination.SetTotal(total)
+
+	var accounts []models.ServiceAccount
+	err := database.DB.Order("id DESC").
+		Offset(pagination.Offset()).
+		Limit(pagination.PerPage).
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// Get returns a single service account
+func (s *ServiceAccountService) Get(id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := database.DB.First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceAccountNotFound
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// Update replaces a service account's settings. The admin making the change
+// grants the permissions and becomes the account's owner. The change applies
+// to requests made with its existing keys right away.
+func (s *ServiceAccountService) Update(id, ownerID uint, ownerRoles []string, input ServiceAccountInput) (*models.ServiceAccount, error) {
+	account, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.grantablePermissions(ownerRoles, input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOrganization(input.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	account.Name = input.Name
+	account.Description = input.Description
+	account.OwnerID = ownerID
+	account.OrganizationID = input.OrganizationID
+	account.Permissions = permissions
+	if err := database.DB.Save(account).Error; err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Delete removes a service account and revokes all of its keys
+func (s *ServiceAccountService) Delete(id uint) error {
+	account, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_account_id = ?", account.ID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(account).Error
+	})
+}
+
+// ListKeys returns the API keys of a service account, newest first
+func (s *ServiceAccountService) ListKeys(id uint) ([]models.APIKey, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+
+	var keys []models.APIKey
+	err := database.DB.Where("service_account_id = ?", id).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// CreateKey issues an API key to a service account and returns it with its
+// secret, which is only available now
+func (s *ServiceAccountService) CreateKey(id uint, expiresAt *time.Time) (*models.APIKey, string, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, "", err
+	}
+
+	return s.issueKey(database.DB, id, expiresAt)
+}
+
+// RotateKey replaces an API key with a new one. The old key keeps working for
+// the grace period so callers can switch over, or stops at once without one.
+func (s *ServiceAccountService) RotateKey(id, keyID uint, expiresAt *time.Time, grace time.Duration) (*models.APIKey, string, error) {
+	if grace < 0 {
+		return nil, "", ErrInvalidGracePeriod
+	}
+
+	old, err := s.getKey(id, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var key *models.APIKey
+	var secret string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, secret, err = s.issueKey(tx, id, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		if grace == 0 {
+			return tx.Delete(old).Error
+		}
+		retireAt := time.Now().Add(grace)
+		if old.ExpiresAt != nil && old.ExpiresAt.Before(retireAt) {
+			return nil
+		}
+		return tx.Model(old).Update("expires_at", retireAt).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+// DeleteKey revokes one of a service account's API keys
+func (s *ServiceAccountService) DeleteKey(id, keyID uint) error {
+	result := database.DB.Where("id = ? AND service_account_id = ?", keyID, id).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate validates an API key, records its use and returns the
+// service account it belongs to. Keys stop working once the account's owner
+// is gone or no longer holds the permissions it granted.
+func (s *ServiceAccountService) Authenticate(secret, ipAddress string) (*models.ServiceAccount, *models.APIKey, error) {
+	var key models.APIKey
+	if err := database.DB.Where("key_hash = ?", utils.HashToken(secret)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if key.IsExpired() {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	account, err := s.Get(key.ServiceAccountID)
+	if errors.Is(err, ErrServiceAccountNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	valid, err := s.ownerGrants(account)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !valid {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > lastSeenInterval || key.LastUsedIP != ipAddress {
+		now := time.Now()
+		key.LastUsedAt = &now
+		key.LastUsedIP = ipAddress
+		if err := database.DB.Model(&key).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ipAddress,
+		}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return account, &key, nil
+}
+
+// issueKey generates and stores a new API key
+func (s *ServiceAccountService) issueKey(db *gorm.DB, id uint, expiresAt *time.Time) (*models.APIKey, string, error) {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidTokenExpiry
+	}
+
+	random, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := APIKeyPrefix + random
+
+	key := models.APIKey{
+		ServiceAccountID: id,
+		KeyHash:          utils.HashToken(secret),
+		Prefix:           secret[:len(APIKeyPrefix)+8],
+		ExpiresAt:        expiresAt,
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &key, secret, nil
+}
+
+// getKey returns one of a service account's API keys
+func (s *ServiceAccountService) getKey(id, keyID uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := database.DB.Where("id = ? AND service_account_id = ?", keyID, id).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ownerGrants reports whether the account's owners still exist and its
+// owning admin's roles still grant all of its permissions
+func (s *ServiceAccountService) ownerGrants(account *models.ServiceAccount) (bool, error) {
+	if account.OrganizationID != nil {
+		if _, err := s.organizationService.Get(*account.OrganizationID); err != nil {
+			if errors.Is(err, ErrOrganizationNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+
+	var owner models.User
+	if err := database.DB.First(&owner, account.OwnerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if owner.IsDisabled() {
+		return false, nil
+	}
+
+	roles, err := s.roleService.RoleNames(database.DB, owner.ID)
+	if err != nil {
+		return false, err
+	}
+	return s.roleService.HasPermissions(roles, account.Permissions)
+}
+
+// checkOrganization checks that the organization an account is given to exists
+func (s *ServiceAccountService) checkOrganization(organizationID *uint) error {
+	if organizationID == nil {
+		return nil
+	}
+	_, err := s.organizationService.Get(*organizationID)
+	return err
+}
+
+// grantablePermissions checks that the roles grant every requested
+// permission, which also ensures each one exists
+func (s *ServiceAccountService) grantablePermissions(roles []string, permissions []string) ([]string, error) {
+	permissions = slices.Compact(slices.Sorted(slices.Values(permissions)))
+
+	allowed, err := s.roleService.HasPermissions(roles, permissions)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrInvalidServiceAccountGrant
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+// setupServiceAccountTest stores an admin whose role grants users:read
+func setupServiceAccountTest(t *testing.T) *models.User {
+	t.Helper()
+
+	setupTestDB(t, &models.Role{}, &models.Permission{}, &models.Organization{}, &models.ServiceAccount{}, &models.APIKey{})
+
+	role := models.Role{
+		Name:        models.RoleAdmin,
+		Permissions: []models.Permission{{Name: models.PermissionUsersRead}},
+	}
+	if err := database.DB.Create(&role).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Create(&models.Role{Name: models.RoleUser}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	admin := createTestUser(t, "admin@example.com")
+	if err := NewRoleService().AssignRoles(database.DB, admin, []string{models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
+
+// createServiceAccountKey creates a service account granted users:read and
+// returns one of its API keys
+func createServiceAccountKey(t *testing.T, service *ServiceAccountService, owner *models.User, organizationID *uint) string {
+	t.Helper()
+
+	account, err := service.Create(owner.ID, []string{models.RoleAdmin}, ServiceAccountInput{
+		Name:           "Exporter",
+		Permissions:    []string{models.PermissionUsersRead},
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	_, secret, err := service.CreateKey(account.ID, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	if _, _, err := service.Authenticate(secret, "192.0.2.1"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	return secret
+}
+
+func TestServiceAccountKeyRejectedAfterOwnerDemoted(t *testing.T) {
+	admin := setupServiceAccountTest(t)
+	service := NewServiceAccountService()
+	secret := createServiceAccountKey(t, service, admin, nil)
+
+	if err := NewRoleService().SetRoles(database.DB, admin, []string{models.RoleUser}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.Authenticate(secret, "192.0.2.1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
+
+func TestServiceAccountKeyRejectedAfterOwnerDeleted(t *testing.T) {
+	admin := setupServiceAccountTest(t)
+	service := NewServiceAccountService()
+	secret := createServiceAccountKey(t, service, admin, nil)
+
+	if err := database.DB.Delete(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.Authenticate(secret, "192.0.2.1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
+
+func TestServiceAccountOwnedByOrganization(t *testing.T) {
+	admin := setupServiceAccountTest(t)
+	service := NewServiceAccountService()
+
+	missing := uint(42)
+	if _, err := service.Create(admin.ID, []string{models.RoleAdmin}, ServiceAccountInput{
+		Name:           "Exporter",
+		OrganizationID: &missing,
+	}); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("Create() for an unknown organization error = %v, want %v", err, ErrOrganizationNotFound)
+	}
+
+	organization := models.Organization{Name: "Acme", Slug: "acme"}
+	if err := database.DB.Create(&organization).Error; err != nil {
+		t.Fatal(err)
+	}
+	secret := createServiceAccountKey(t, service, admin, &organization.ID)
+
+	if err := database.DB.Delete(&organization).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.Authenticate(secret, "192.0.2.1"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Authenticate() after the organization was deleted error = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session_not_found")
+	ErrSessionRevoked  = errors.New("session_revoked")
+)
+
+// lastSeenInterval limits how often a session's last-seen time is written
+const lastSeenInterval = time.Minute
+
+type SessionService struct{}
+
+// NewSessionService creates a new session service
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+// Create records a new session for a user signing in from the given device
+func (s *SessionService) Create(userID uint, ipAddress, userAgent string) (*models.Session, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		LastSeenAt: now,
+		ExpiresAt:  now.AddDate(0, 0, config.AppConfig.RefreshTokenDays),
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Touch validates a session and records activity on it
+func (s *SessionService) Touch(sessionID, ipAddress string) (*models.Session, error) {
+	var session models.Session
+	if err := database.DB.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if !session.IsActive() {
+		return nil, ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > lastSeenInterval {
+		session.LastSeenAt = time.Now()
+		session.IPAddress = ipAddress
+		if err := database.DB.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": session.LastSeenAt,
+			"ip_address":   session.IPAddress,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+// Active reports whether a session exists and has not ended, without
+// recording activity on it
+func (s *SessionService) Active(sessionID string) (bool, error) {
+	var session models.Session
+	if err := database.DB.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return session.IsActive(), nil
+}
+
+// Extend pushes back the expiry of a session after its refresh token rotates
+func (s *SessionService) Extend(db *gorm.DB, sessionID string) error {
+	now := time.Now()
+	return db.Model(&models.Session{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   now.AddDate(0, 0, config.AppConfig.RefreshTokenDays),
+	}).Error
+}
+
+// List returns the active sessions of a user, most recently used first
+func (s *SessionService) List(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke signs out a single session owned by the user
+func (s *SessionService) Revoke(userID uint, sessionID string) error {
+	revoked, err := s.revoke("id = ? AND user_id = ?", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOthers signs out every session of the user except the current one
+func (s *SessionService) RevokeOthers(userID uint, currentSessionID string) error {
+	_, err := s.revoke("user_id = ? AND id <> ?", userID, currentSessionID)
+	return err
+}
+
+// RevokeAll signs out every session of the user
+func (s *SessionService) RevokeAll(userID uint) error {
+	_, err := s.revoke("user_id = ?", userID)
+	return err
+}
+
+// Purge deletes ended sessions and refresh tokens that can no longer be
+// exchanged. Rotated tokens of active sessions are kept until they expire so
+// replaying one is still caught as reuse.
+func (s *SessionService) Purge() error {
+	now := time.Now()
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		endedSessions := tx.Model(&models.Session{}).Select("id").
+			Where("revoked_at IS NOT NULL OR expires_at <= ?", now)
+		if err := tx.Where("expires_at <= ? OR revoked_at IS NOT NULL OR session_id IN (?)", now, endedSessions).
+			Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("revoked_at IS NOT NULL OR expires_at <= ?", now).Delete(&models.Session{}).Error
+	})
+}
+
+// revoke marks matching active sessions and their refresh tokens as revoked
+// and returns how many sessions were affected
+func (s *SessionService) revoke(query string, args ...interface{}) (int, error) {
+	var sessionIDs []string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).Where(query, args...).Where("revoked_at IS NULL").
+			Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).Where("id IN ?", sessionIDs).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).Where("session_id IN ? AND revoked_at IS NULL", sessionIDs).
+			Update("revoked_at", now).Error
+	})
+
+	return len(sessionIDs), err
+}
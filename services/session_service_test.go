@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+func TestSessionPurge(t *testing.T) {
+	setupTestDB(t, &models.Session{}, &models.RefreshToken{})
+	user := createTestUser(t, "sessions@example.com")
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	sessions := []models.Session{
+		{ID: "active", UserID: user.ID, ExpiresAt: future},
+		{ID: "expired", UserID: user.ID, ExpiresAt: past},
+		{ID: "revoked", UserID: user.ID, ExpiresAt: future, RevokedAt: &past},
+	}
+	if err := database.DB.Create(&sessions).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := []models.RefreshToken{
+		{TokenHash: "active-current", SessionID: "active", ExpiresAt: future},
+		{TokenHash: "active-rotated", SessionID: "active", ExpiresAt: future, UsedAt: &past},
+		{TokenHash: "active-revoked", SessionID: "active", ExpiresAt: future, RevokedAt: &past},
+		{TokenHash: "active-expired", SessionID: "active", ExpiresAt: past},
+		{TokenHash: "expired-session", SessionID: "expired", ExpiresAt: future},
+		{TokenHash: "revoked-session", SessionID: "revoked", ExpiresAt: future},
+	}
+	for i := range tokens {
+		tokens[i].UserID = user.ID
+	}
+	if err := database.DB.Create(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewSessionService().Purge(); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	var sessionIDs []string
+	database.DB.Model(&models.Session{}).Order("id").Pluck("id", &sessionIDs)
+	if len(sessionIDs) != 1 || sessionIDs[0] != "active" {
+		t.Errorf("remaining sessions = %v, want [active]", sessionIDs)
+	}
+
+	// The rotated token is kept so replaying it still revokes the session
+	var tokenHashes []string
+	database.DB.Model(&models.RefreshToken{}).Order("token_hash").Pluck("token_hash", &tokenHashes)
+	if len(tokenHashes) != 2 || tokenHashes[0] != "active-current" || tokenHashes[1] != "active-rotated" {
+		t.Errorf("remaining refresh tokens = %v, want [active-current active-rotated]", tokenHashes)
+	}
+}
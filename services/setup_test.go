@@ -0,0 +1,127 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB loads the default configuration and points database.DB at a
+// fresh in-memory database holding the given models
+func setupTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+
+	config.LoadConfig()
+	if err := utils.LoadSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables = append([]interface{}{&models.User{}, &models.RevokedToken{}}, tables...)
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	database.DB = db
+}
+
+// createTestUser stores a user with a verified email address
+func createTestUser(t *testing.T, email string) *models.User {
+	t.Helper()
+
+	user := models.User{Email: email, Name: "Test User", IsEmailVerified: true}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// addTestCredentials gives the user TOTP with a recovery code, a passkey, a
+// personal access token and a linked external identity
+func addTestCredentials(t *testing.T, user *models.User) {
+	t.Helper()
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"totp_enabled": true,
+		"totp_secret":  "JBSWY3DPEHPK3PXP",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := []interface{}{
+		&models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashToken("recovery")},
+		&models.WebAuthnCredential{UserID: user.ID, Name: "Key", CredentialIDHash: utils.HashToken("passkey"), CredentialID: "passkey", PublicKey: []byte{1}, UserHandle: "handle"},
+		&models.PersonalAccessToken{UserID: user.ID, Name: "Script", TokenHash: utils.HashToken("pat")},
+		&models.ExternalIdentity{UserID: user.ID, Provider: "other", Subject: "squatter"},
+	}
+	for _, credential := range credentials {
+		if err := database.DB.Create(credential).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// assertCredentialsReset fails the test if the user kept any credential added
+// by addTestCredentials
+func assertCredentialsReset(t *testing.T, userID uint) {
+	t.Helper()
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != "" {
+		t.Error("password was kept")
+	}
+	if user.TOTPEnabled || user.TOTPSecret != "" {
+		t.Error("TOTP enrolment was kept")
+	}
+
+	for name, credential := range map[string]interface{}{
+		"recovery codes":         &models.RecoveryCode{},
+		"passkeys":               &models.WebAuthnCredential{},
+		"personal access tokens": &models.PersonalAccessToken{},
+	} {
+		var count int64
+		if err := database.DB.Model(credential).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%d %s were kept", count, name)
+		}
+	}
+
+	// The new owner may have just linked an identity of their own
+	var identities int64
+	if err := database.DB.Model(&models.ExternalIdentity{}).
+		Where("user_id = ? AND subject = ?", userID, "squatter").
+		Count(&identities).Error; err != nil {
+		t.Fatal(err)
+	}
+	if identities != 0 {
+		t.Error("external identity was kept")
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidOAuthState        = errors.New("invalid_oauth_state")
+	ErrExternalEmailNotVerified = errors.New("external_email_not_verified")
+	ErrExternalEmailUnavailable = errors.New("email_exists")
+	ErrIdentityAlreadyLinked    = errors.New("identity_already_linked")
+	ErrIdentityNotFound         = errors.New("identity_not_found")
+	ErrLastSignInMethod         = errors.New("last_sign_in_method")
+)
+
+type SocialLoginService struct {
+	roleService     *RoleService
+	tokenService    *TokenService
+	webauthnService *WebAuthnService
+}
+
+// NewSocialLoginService creates a new social login service
+func NewSocialLoginService() *SocialLoginService {
+	return &SocialLoginService{
+		roleService:     NewRoleService(),
+		tokenService:    NewTokenService(),
+		webauthnService: NewWebAuthnService(),
+	}
+}
+
+// Begin starts an authorization code flow with a provider and returns the
+// URL to send the user to and the session token the callback must present.
+// userID is set when linking the identity to a signed-in user.
+func (s *SocialLoginService) Begin(ctx context.Context, providerName string, userID uint) (string, string, error) {
+	provider, err := GetIdentityProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := utils.GenerateCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthorizationURL(ctx, state, nonce, utils.CodeChallengeS256(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionToken, err := utils.GenerateOAuthStateToken(utils.OAuthStateClaims{
+		Provider:     provider.Name(),
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		UserID:       userID,
+	}, time.Duration(config.AppConfig.OAuthStateMinutes)*time.Minute)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, sessionToken, nil
+}
+
+// Login completes a sign-in flow and returns the user the external identity
+// belongs to. Unknown identities are linked to the account with the same
+// verified email, or to a new account if there is none.
+func (s *SocialLoginService) Login(ctx context.Context, providerName, sessionToken, state, code string) (*models.User, error) {
+	profile, err := s.complete(ctx, providerName, sessionToken, state, code, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity models.ExternalIdentity
+	err = database.DB.Where("provider = ? AND subject = ?", profile.Provider, profile.Subject).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := database.DB.First(&user, identity.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrAccountDisabled
+			}
+			return nil, err
+		}
+
+		updates := map[string]interface{}{"last_login_at": time.Now()}
+		if profile.Email != "" {
+			updates["email"] = profile.Email
+		}
+		if err := database.DB.Model(&identity).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if profile.Email == "" || !profile.EmailVerified {
+		return nil, ErrExternalEmailNotVerified
+	}
+
+	var user models.User
+	takeover := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("email = ?", profile.Email).First(&user).Error
+		switch {
+		case err == nil && user.DeletedAt.Valid:
+			return ErrExternalEmailUnavailable
+		case err == nil:
+			// Whoever registered an unverified account with this email may
+			// not own it, so drop everything they set up and sign them out
+			if !user.IsEmailVerified {
+				takeover = true
+				user.IsEmailVerified = true
+				if err := tx.Model(&user).Update("is_email_verified", true).Error; err != nil {
+					return err
+				}
+				if err := ResetAccountCredentials(tx, &user); err != nil {
+					return err
+				}
+				if err := s.roleService.AssignConfiguredAdmin(tx, &user); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = models.User{
+				Email:           profile.Email,
+				Name:            externalDisplayName(profile),
+				IsEmailVerified: true,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := s.roleService.AssignDefaultRoles(tx, &user); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		now := time.Now()
+		return tx.Create(&models.ExternalIdentity{
+			UserID:      user.ID,
+			Provider:    profile.Provider,
+			Subject:     profile.Subject,
+			Email:       profile.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if takeover {
+		if _, err := s.tokenService.InvalidateUserTokens(&user, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// Link completes a linking flow and attaches the external identity to the
+// signed-in user
+func (s *SocialLoginService) Link(ctx context.Context, userID uint, providerName, sessionToken, state, code string) (*models.ExternalIdentity, error) {
+	profile, err := s.complete(ctx, providerName, sessionToken, state, code, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.ExternalIdentity
+	err = database.DB.Where("provider = ? AND subject = ?", profile.Provider, profile.Subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID == userID {
+			return &existing, nil
+		}
+		return nil, ErrIdentityAlreadyLinked
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	identity := models.ExternalIdentity{
+		UserID:   userID,
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+	if err := database.DB.Create(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// List returns the external identities linked to the user
+func (s *SocialLoginService) List(userID uint) ([]models.ExternalIdentity, error) {
+	var identities []models.ExternalIdentity
+	err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// Unlink removes an external identity from the user. The last identity
+// cannot be removed from an account without a password or passkey.
+func (s *SocialLoginService) Unlink(user *models.User, id uint) error {
+	var identity models.ExternalIdentity
+	if err := database.DB.Where("id = ? AND user_id = ?", id, user.ID).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+
+	if user.Password == "" {
+		var others int64
+		if err := database.DB.Model(&models.ExternalIdentity{}).
+			Where("user_id = ? AND id <> ?", user.ID, identity.ID).
+			Count(&others).Error; err != nil {
+			return err
+		}
+
+		passkeys, err := s.webauthnService.Count(user.ID)
+		if err != nil {
+			return err
+		}
+
+		if others == 0 && passkeys == 0 {
+			return ErrLastSignInMethod
+		}
+	}
+
+	return database.DB.Delete(&identity).Error
+}
+
+// complete validates the session token and state of a callback and redeems
+// the authorization code
+func (s *SocialLoginService) complete(ctx context.Context, providerName, sessionToken, state, code string, userID uint) (*ExternalProfile, error) {
+	provider, err := GetIdentityProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &utils.OAuthStateClaims{}
+	if err := utils.ParseClaims(sessionToken, claims); err != nil || claims.TokenUse != utils.TokenUseOAuthState {
+		return nil, ErrInvalidOAuthState
+	}
+	if claims.Provider != provider.Name() || claims.UserID != userID ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return nil, ErrInvalidOAuthState
+	}
+
+	revoked, err := Revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidOAuthState
+	}
+	if err := Revocations.Revoke(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	return provider.Exchange(ctx, code, claims.CodeVerifier, claims.Nonce)
+}
+
+// externalDisplayName picks a name for an account created from a profile
+func externalDisplayName(profile *ExternalProfile) string {
+	if name := strings.TrimSpace(profile.Name); name != "" {
+		return name
+	}
+	local, _, _ := strings.Cut(profile.Email, "@")
+	return local
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/internal/oidctest"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+)
+
+const testProviderName = "stand-in"
+
+func setupSocialLoginTest(t *testing.T) (*SocialLoginService, *oidctest.Provider) {
+	t.Helper()
+
+	setupTestDB(t, &models.Role{}, &models.ExternalIdentity{}, &models.Session{}, &models.RefreshToken{}, &models.WebAuthnCredential{},
+		&models.RecoveryCode{}, &models.PersonalAccessToken{})
+	for _, name := range []string{models.RoleAdmin, models.RoleUser} {
+		if err := database.DB.Create(&models.Role{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider := oidctest.NewProvider("test-client", "test-secret")
+	t.Cleanup(provider.Close)
+	RegisterIdentityProvider(NewIdentityProvider(provider.Config(testProviderName, "https://app.example/auth/callback")))
+
+	return NewSocialLoginService(), provider
+}
+
+// beginSocialLogin starts a sign-in flow and returns the authorization URL,
+// session token and state
+func beginSocialLogin(t *testing.T, service *SocialLoginService) (string, string, string) {
+	t.Helper()
+
+	authURL, sessionToken, err := service.Begin(context.Background(), testProviderName, 0)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL, sessionToken, parsed.Query().Get("state")
+}
+
+// socialLogin runs a complete sign-in flow as the provider user
+func socialLogin(t *testing.T, service *SocialLoginService, provider *oidctest.Provider, subject string) (*models.User, error) {
+	t.Helper()
+
+	authURL, sessionToken, _ := beginSocialLogin(t, service)
+	code, state, err := provider.Authorize(authURL, subject)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	return service.Login(context.Background(), testProviderName, sessionToken, state, code)
+}
+
+func TestSocialLoginCreatesAccount(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "Alice@Example.com", EmailVerified: true, Name: "Alice"})
+
+	user, err := socialLogin(t, service, provider, "alice")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if user.Email != "alice@example.com" || user.Name != "Alice" || !user.IsEmailVerified {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	roles, err := NewRoleService().RoleNames(database.DB, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(roles, []string{models.RoleUser}) {
+		t.Errorf("roles = %v, want [%s]", roles, models.RoleUser)
+	}
+
+	// Signing in again finds the account through the linked identity
+	again, err := socialLogin(t, service, provider, "alice")
+	if err != nil {
+		t.Fatalf("second Login() error = %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second Login() user = %d, want %d", again.ID, user.ID)
+	}
+}
+
+func TestSocialLoginRejectsStateMismatch(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	authURL, sessionToken, _ := beginSocialLogin(t, service)
+	code, _, err := provider.Authorize(authURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.Login(context.Background(), testProviderName, sessionToken, "forged-state", code)
+	if !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("Login() error = %v, want %v", err, ErrInvalidOAuthState)
+	}
+}
+
+func TestSocialLoginRejectsReplayedSession(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	authURL, sessionToken, _ := beginSocialLogin(t, service)
+	code, state, err := provider.Authorize(authURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Login(context.Background(), testProviderName, sessionToken, state, code); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	code, _, err = provider.Authorize(authURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = service.Login(context.Background(), testProviderName, sessionToken, state, code)
+	if !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("replayed Login() error = %v, want %v", err, ErrInvalidOAuthState)
+	}
+}
+
+func TestSocialLoginRejectsNonceMismatch(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	authURL, sessionToken, state := beginSocialLogin(t, service)
+
+	// The ID token is issued for a different authorization request
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	query.Set("nonce", "another-nonce")
+	parsed.RawQuery = query.Encode()
+
+	code, _, err := provider.Authorize(parsed.String(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.Login(context.Background(), testProviderName, sessionToken, state, code)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("Login() error = %v, want %v", err, ErrInvalidIDToken)
+	}
+}
+
+func TestSocialLoginRejectsPKCEVerifierMismatch(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	_, sessionToken, state := beginSocialLogin(t, service)
+
+	// A code issued to another flow cannot be redeemed with this flow's
+	// code verifier
+	otherURL, _, _ := beginSocialLogin(t, service)
+	code, _, err := provider.Authorize(otherURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.Login(context.Background(), testProviderName, sessionToken, state, code)
+	if !errors.Is(err, ErrIdentityProviderFailed) {
+		t.Errorf("Login() error = %v, want %v", err, ErrIdentityProviderFailed)
+	}
+}
+
+func TestSocialLoginRejectsUnverifiedEmail(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "mallory", Email: "victim@example.com", EmailVerified: false})
+	victim := createTestUser(t, "victim@example.com")
+
+	_, err := socialLogin(t, service, provider, "mallory")
+	if !errors.Is(err, ErrExternalEmailNotVerified) {
+		t.Errorf("Login() error = %v, want %v", err, ErrExternalEmailNotVerified)
+	}
+
+	var identities int64
+	database.DB.Model(&models.ExternalIdentity{}).Where("user_id = ?", victim.ID).Count(&identities)
+	if identities != 0 {
+		t.Errorf("unverified identity was linked to the existing account")
+	}
+}
+
+func TestSocialLoginLinksAccountWithVerifiedEmail(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "alice", Email: "alice@example.com", EmailVerified: true})
+
+	existing := models.User{Email: "alice@example.com", Name: "Alice", Password: "Correct-horse-9", IsEmailVerified: true}
+	if err := database.DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := socialLogin(t, service, provider, "alice")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("Login() user = %d, want existing user %d", user.ID, existing.ID)
+	}
+
+	var stored models.User
+	if err := database.DB.First(&stored, existing.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.CheckPassword("Correct-horse-9") {
+		t.Error("linking a verified account changed its password")
+	}
+
+	identities, err := service.List(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Provider != testProviderName || identities[0].Subject != "alice" {
+		t.Errorf("unexpected identities %+v", identities)
+	}
+}
+
+func TestSocialLoginTakesOverUnverifiedAccount(t *testing.T) {
+	service, provider := setupSocialLoginTest(t)
+	provider.AddUser(oidctest.User{Subject: "owner", Email: "owner@example.com", EmailVerified: true})
+	config.AppConfig.AdminEmails = []string{"owner@example.com"}
+
+	// Someone registered the owner's email first, set up other ways to sign
+	// in and is still signed in
+	squatter := models.User{Email: "owner@example.com", Name: "Squatter", Password: "Squatter-pass-9"}
+	if err := database.DB.Create(&squatter).Error; err != nil {
+		t.Fatal(err)
+	}
+	addTestCredentials(t, &squatter)
+	if err := NewRoleService().AssignDefaultRoles(database.DB, &squatter); err != nil {
+		t.Fatal(err)
+	}
+	session := models.Session{ID: "squatter-session", UserID: squatter.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := socialLogin(t, service, provider, "owner")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if user.ID != squatter.ID {
+		t.Fatalf("Login() user = %d, want %d", user.ID, squatter.ID)
+	}
+
+	var stored models.User
+	if err := database.DB.First(&stored, squatter.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsEmailVerified {
+		t.Error("account was not marked verified")
+	}
+	if stored.TokenVersion <= squatter.TokenVersion {
+		t.Error("squatter's access tokens were not invalidated")
+	}
+
+	assertCredentialsReset(t, squatter.ID)
+
+	identities, err := service.List(squatter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Provider != testProviderName || identities[0].Subject != "owner" {
+		t.Errorf("identities = %+v, want only the owner's", identities)
+	}
+
+	if err := database.DB.First(&session, "id = ?", session.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.RevokedAt == nil {
+		t.Error("squatter's session was not revoked")
+	}
+
+	roles, err := NewRoleService().RoleNames(database.DB, squatter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(roles, models.RoleAdmin) {
+		t.Errorf("roles = %v, want the configured admin role once verified", roles)
+	}
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid_refresh_token")
+	ErrRefreshTokenExpired = errors.New("refresh_token_expired")
+	ErrRefreshTokenReused  = errors.New("refresh_token_reused")
+	ErrAccountDisabled     = errors.New("account_disabled")
+	ErrTokenInvalidated    = errors.New("token_invalidated")
+)
+
+// TokenPair is the access/refresh token pair returned to clients
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type TokenService struct {
+	sessionService      *SessionService
+	roleService         *RoleService
+	organizationService *OrganizationService
+}
+
+// NewTokenService creates a new token service
+func NewTokenService() *TokenService {
+	return &TokenService{
+		sessionService:      NewSessionService(),
+		roleService:         NewRoleService(),
+		organizationService: NewOrganizationService(),
+	}
+}
+
+// IssueTokenPair issues an access token and a refresh token for a session
+func (s *TokenService) IssueTokenPair(user *models.User, session *models.Session) (*TokenPair, error) {
+	return s.issue(database.DB, user, session.ID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is consumed; presenting an already used token revokes its session.
+func (s *TokenService) Refresh(rawToken string) (*TokenPair, *models.User, error) {
+	var stored models.RefreshToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(rawToken)).First(&stored).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		// Replay of a rotated token: assume it was stolen and kill the session
+		if err := s.sessionService.Revoke(stored.UserID, stored.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, nil, ErrRefreshTokenExpired
+	}
+
+	var session models.Session
+	if err := database.DB.Where("id = ?", stored.SessionID).First(&session).Error; err != nil || !session.IsActive() {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, stored.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if user.IsDisabled() {
+		return nil, nil, ErrAccountDisabled
+	}
+
+	var pair *TokenPair
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so two concurrent refreshes cannot both succeed
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		if err := s.sessionService.Extend(tx, session.ID); err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = s.issue(tx, &user, session.ID)
+		return err
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if err := s.sessionService.Revoke(stored.UserID, stored.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pair, &user, nil
+}
+
+// InvalidateUserTokens bumps the user's token version so every access token
+// issued so far is rejected, and signs out all of their sessions. If
+// keepSessionID is set that session survives and gets a fresh token pair.
+func (s *TokenService) InvalidateUserTokens(user *models.User, keepSessionID string) (*TokenPair, error) {
+	err := database.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).
+		Pluck("token_version", &user.TokenVersion).Error; err != nil {
+		return nil, err
+	}
+
+	if keepSessionID == "" {
+		return nil, s.sessionService.RevokeAll(user.ID)
+	}
+
+	if err := s.sessionService.RevokeOthers(user.ID, keepSessionID); err != nil {
+		return nil, err
+	}
+
+	var pair *TokenPair
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The kept session's old refresh tokens are replaced by the new pair
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", keepSessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = s.issue(tx, user, keepSessionID)
+		return err
+	})
+
+	return pair, err
+}
+
+// SwitchOrganization makes an organization the session's active one, or
+// clears it when organizationID is 0, and issues a token pair carrying it.
+// The session's previous refresh tokens stop working.
+func (s *TokenService) SwitchOrganization(user *models.User, sessionID string, organizationID uint) (*TokenPair, error) {
+	var activeOrganization *uint
+	if organizationID != 0 {
+		if _, err := s.organizationService.Membership(organizationID, user.ID); err != nil {
+			return nil, err
+		}
+		activeOrganization = &organizationID
+	}
+
+	var pair *TokenPair
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).Where("id = ?", sessionID).
+			Update("organization_id", activeOrganization).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("session_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = s.issue(tx, user, sessionID)
+		return err
+	})
+
+	return pair, err
+}
+
+// TokenVersion returns the user's current token version
+func (s *TokenService) TokenVersion(userID uint) (uint, error) {
+	var versions []uint
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).
+		Pluck("token_version", &versions).Error; err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, ErrTokenInvalidated
+	}
+	return versions[0], nil
+}
+
+// issue signs an access token carrying the session's active organization and
+// persists a new refresh token for the session
+func (s *TokenService) issue(db *gorm.DB, user *models.User, sessionID string) (*TokenPair, error) {
+	roles, err := s.roleService.RoleNames(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := utils.Claims{
+		UserID:       user.ID,
+		Email:        user.Email,
+		SessionID:    sessionID,
+		Roles:        roles,
+		TokenVersion: user.TokenVersion,
+	}
+
+	membership, err := s.organizationService.SessionMembership(db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if membership != nil {
+		claims.OrganizationID = membership.OrganizationID
+		claims.OrganizationRole = membership.Role
+	}
+
+	accessToken, err := utils.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	rawRefreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(rawRefreshToken),
+		SessionID: sessionID,
+		ExpiresAt: time.Now().AddDate(0, 0, config.AppConfig.RefreshTokenDays),
+	}
+
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawRefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+func setupTokenTest(t *testing.T) (*TokenService, *models.User, []*TokenPair, []*models.Session) {
+	t.Helper()
+
+	setupTestDB(t, &models.Role{}, &models.Session{}, &models.RefreshToken{},
+		&models.Organization{}, &models.OrganizationMembership{})
+	user := createTestUser(t, "tokens@example.com")
+	service := NewTokenService()
+
+	var pairs []*TokenPair
+	var sessions []*models.Session
+	for range 2 {
+		session, err := NewSessionService().Create(user.ID, "192.0.2.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pair, err := service.IssueTokenPair(user, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+		sessions = append(sessions, session)
+	}
+	return service, user, pairs, sessions
+}
+
+func TestInvalidateUserTokensSignsOutEverySession(t *testing.T) {
+	service, user, pairs, sessions := setupTokenTest(t)
+
+	pair, err := service.InvalidateUserTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair != nil {
+		t.Fatal("got a token pair without a session to keep")
+	}
+
+	if version, _ := service.TokenVersion(user.ID); version != 1 || user.TokenVersion != 1 {
+		t.Fatalf("token version = %d (user %d), want 1", version, user.TokenVersion)
+	}
+	for i, session := range sessions {
+		if active, _ := NewSessionService().Active(session.ID); active {
+			t.Errorf("session %d is still active", i)
+		}
+		if _, _, err := service.Refresh(pairs[i].RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refreshing session %d: got %v, want %v", i, err, ErrInvalidRefreshToken)
+		}
+	}
+}
+
+// TestInvalidateUserTokensKeepsCurrentSession covers a password change, which
+// signs out other devices but leaves the one making the change signed in
+func TestInvalidateUserTokensKeepsCurrentSession(t *testing.T) {
+	service, user, pairs, sessions := setupTokenTest(t)
+
+	pair, err := service.InvalidateUserTokens(user, sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair == nil {
+		t.Fatal("the kept session got no token pair")
+	}
+
+	claims, err := utils.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.TokenVersion != 1 || claims.SessionID != sessions[0].ID {
+		t.Fatalf("new access token has version %d for session %q", claims.TokenVersion, claims.SessionID)
+	}
+
+	if active, _ := NewSessionService().Active(sessions[0].ID); !active {
+		t.Error("the kept session was signed out")
+	}
+	if active, _ := NewSessionService().Active(sessions[1].ID); active {
+		t.Error("the other session is still active")
+	}
+
+	// The kept session's earlier refresh token was issued before the change
+	for i, old := range pairs {
+		if _, _, err := service.Refresh(old.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("refreshing with the old token of session %d: got %v, want %v", i, err, ErrInvalidRefreshToken)
+		}
+	}
+	if _, _, err := service.Refresh(pair.RefreshToken); err != nil {
+		t.Errorf("refreshing with the new token: %v", err)
+	}
+}
@@ -0,0 +1,546 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/database"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidWebAuthnSession   = errors.New("invalid_webauthn_session")
+	ErrPasskeyNotFound          = errors.New("passkey_not_found")
+	ErrPasskeyAlreadyRegistered = errors.New("passkey_already_registered")
+	// ErrPasskeySignCount means the authenticator's signature counter did not
+	// increase, which suggests the credential has been cloned
+	ErrPasskeySignCount = errors.New("passkey_sign_count_invalid")
+)
+
+// User verification requirements
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+const (
+	defaultPasskeyName = "Passkey"
+	// maxCredentialIDLength is the limit set by the WebAuthn specification
+	maxCredentialIDLength = 1023
+	userHandleLength      = 32
+	challengeLength       = 32
+)
+
+// supportedCOSEAlgorithms are offered to authenticators in order of preference
+var supportedCOSEAlgorithms = []int64{utils.COSEAlgES256, utils.COSEAlgEdDSA, utils.COSEAlgRS256}
+
+// RelyingParty identifies this server to authenticators
+type RelyingParty struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUser describes the account a credential is created for
+type WebAuthnUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is an acceptable credential type and algorithm
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies an existing credential
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states the authenticator features the server wants
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CredentialCreationOptions are passed to navigator.credentials.create()
+// as the publicKey member, with binary fields base64url encoded
+type CredentialCreationOptions struct {
+	RP                     RelyingParty           `json:"rp"`
+	User                   WebAuthnUser           `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// CredentialRequestOptions are passed to navigator.credentials.get() as the
+// publicKey member, with binary fields base64url encoded
+type CredentialRequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type WebAuthnService struct{}
+
+// NewWebAuthnService creates a new WebAuthn service
+func NewWebAuthnService() *WebAuthnService {
+	return &WebAuthnService{}
+}
+
+// BeginRegistration creates the options for registering a new passkey and
+// the session token that must be sent back with the result
+func (s *WebAuthnService) BeginRegistration(user *models.User) (*CredentialCreationOptions, string, error) {
+	credentials, err := s.List(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// All of a user's credentials share one user handle
+	var userHandle string
+	if len(credentials) > 0 {
+		userHandle = credentials[0].UserHandle
+	} else {
+		handle, err := utils.GenerateRandomBytes(userHandleLength)
+		if err != nil {
+			return nil, "", err
+		}
+		userHandle = utils.EncodeBase64URL(handle)
+	}
+
+	challenge, sessionToken, err := s.newSession(utils.WebAuthnSessionClaims{
+		UserID:           user.ID,
+		UserHandle:       userHandle,
+		Ceremony:         utils.WebAuthnCeremonyCreate,
+		UserVerification: UserVerificationPreferred,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	params := make([]CredentialParameter, 0, len(supportedCOSEAlgorithms))
+	for _, alg := range supportedCOSEAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Algorithm: alg})
+	}
+
+	options := &CredentialCreationOptions{
+		RP: RelyingParty{ID: config.AppConfig.WebAuthnRPID, Name: config.AppConfig.WebAuthnRPName},
+		User: WebAuthnUser{
+			ID:          userHandle,
+			Name:        user.Email,
+			DisplayName: user.Name,
+		},
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            webAuthnTimeout().Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(credentials),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: UserVerificationPreferred,
+		},
+		Attestation: "none",
+	}
+
+	return options, sessionToken, nil
+}
+
+// FinishRegistration verifies a newly created credential and stores it.
+// Attestation statements are not verified, so every credential is treated as
+// if it had been created with attestation "none".
+func (s *WebAuthnService) FinishRegistration(user *models.User, sessionToken, name string, credential *utils.PublicKeyCredential) (*models.WebAuthnCredential, error) {
+	session, err := s.useSession(sessionToken, utils.WebAuthnCeremonyCreate)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != user.ID {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	rawID, clientDataJSON, err := decodeCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.VerifyClientData(clientDataJSON, utils.WebAuthnCeremonyCreate, session.Challenge, config.AppConfig.WebAuthnOrigins); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := utils.DecodeBase64URL(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object", utils.ErrWebAuthnVerification)
+	}
+	authData, _, err := utils.ParseAttestationObject(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+
+	if authData.CredentialID == nil || !bytes.Equal(authData.CredentialID, rawID) || len(rawID) > maxCredentialIDLength {
+		return nil, fmt.Errorf("%w: invalid credential ID", utils.ErrWebAuthnVerification)
+	}
+	if _, err := utils.ParseCOSEKey(authData.CredentialPublicKey); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	record := models.WebAuthnCredential{
+		UserID:           user.ID,
+		Name:             name,
+		CredentialIDHash: utils.HashToken(string(rawID)),
+		CredentialID:     utils.EncodeBase64URL(rawID),
+		PublicKey:        authData.CredentialPublicKey,
+		SignCount:        authData.SignCount,
+		UserHandle:       session.UserHandle,
+		AAGUID:           formatAAGUID(authData.AAGUID),
+		Transports:       strings.Join(credential.Response.Transports, ","),
+		BackupEligible:   authData.BackupEligible(),
+		BackedUp:         authData.BackedUp(),
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.WebAuthnCredential{}).Where("credential_id_hash = ?", record.CredentialIDHash).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrPasskeyAlreadyRegistered
+	}
+
+	if err := database.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// BeginLogin creates the options for authenticating with a passkey. With a
+// nil user any discoverable credential is accepted and the user is identified
+// from the assertion.
+func (s *WebAuthnService) BeginLogin(user *models.User, userVerification string) (*CredentialRequestOptions, string, error) {
+	claims := utils.WebAuthnSessionClaims{
+		Ceremony:         utils.WebAuthnCeremonyGet,
+		UserVerification: userVerification,
+	}
+
+	allowCredentials := []CredentialDescriptor{}
+	if user != nil {
+		credentials, err := s.List(user.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(credentials) == 0 {
+			return nil, "", ErrPasskeyNotFound
+		}
+
+		claims.UserID = user.ID
+		allowCredentials = credentialDescriptors(credentials)
+	}
+
+	challenge, sessionToken, err := s.newSession(claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	options := &CredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          webAuthnTimeout().Milliseconds(),
+		RPID:             config.AppConfig.WebAuthnRPID,
+		AllowCredentials: allowCredentials,
+		UserVerification: userVerification,
+	}
+
+	return options, sessionToken, nil
+}
+
+// FinishLogin verifies an assertion from a passwordless login and returns
+// the user owning the credential
+func (s *WebAuthnService) FinishLogin(sessionToken string, credential *utils.PublicKeyCredential) (*models.User, error) {
+	session, err := s.useSession(sessionToken, utils.WebAuthnCeremonyGet)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != 0 || session.UserVerification != UserVerificationRequired {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	record, err := s.verifyAssertion(session, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, record.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// VerifyAssertion verifies an assertion made by one of the user's passkeys,
+// for use as a second factor
+func (s *WebAuthnService) VerifyAssertion(userID uint, sessionToken string, credential *utils.PublicKeyCredential) error {
+	session, err := s.useSession(sessionToken, utils.WebAuthnCeremonyGet)
+	if err != nil {
+		return err
+	}
+	if session.UserID == 0 || session.UserID != userID {
+		return ErrInvalidWebAuthnSession
+	}
+
+	_, err = s.verifyAssertion(session, credential)
+	return err
+}
+
+// List returns the user's registered passkeys
+func (s *WebAuthnService) List(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+// Count returns the number of passkeys the user has registered
+func (s *WebAuthnService) Count(userID uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// Rename changes the display name of one of the user's passkeys
+func (s *WebAuthnService) Rename(userID, id uint, name string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	if err := database.DB.Model(&credential).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// Delete removes one of the user's passkeys
+func (s *WebAuthnService) Delete(userID, id uint) error {
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// newSession generates a challenge and signs it into a session token
+func (s *WebAuthnService) newSession(claims utils.WebAuthnSessionClaims) (string, string, error) {
+	challenge, err := utils.GenerateRandomBytes(challengeLength)
+	if err != nil {
+		return "", "", err
+	}
+
+	claims.Challenge = utils.EncodeBase64URL(challenge)
+	sessionToken, err := utils.GenerateWebAuthnSessionToken(claims, webAuthnTimeout())
+	if err != nil {
+		return "", "", err
+	}
+
+	return claims.Challenge, sessionToken, nil
+}
+
+// useSession validates a session token and revokes it so each challenge can
+// only be answered once
+func (s *WebAuthnService) useSession(sessionToken, ceremony string) (*utils.WebAuthnSessionClaims, error) {
+	claims := &utils.WebAuthnSessionClaims{}
+	if err := utils.ParseClaims(sessionToken, claims); err != nil || claims.TokenUse != utils.TokenUseWebAuthn || claims.Ceremony != ceremony {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	revoked, err := Revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	if err := Revocations.Revoke(claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// verifyAssertion checks an assertion against the stored credential and
+// advances its signature counter
+func (s *WebAuthnService) verifyAssertion(session *utils.WebAuthnSessionClaims, credential *utils.PublicKeyCredential) (*models.WebAuthnCredential, error) {
+	rawID, clientDataJSON, err := decodeCredential(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.WebAuthnCredential
+	query := database.DB.Where("credential_id_hash = ?", utils.HashToken(string(rawID)))
+	if session.UserID != 0 {
+		query = query.Where("user_id = ?", session.UserID)
+	}
+	if err := query.First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasskeyNotFound
+		}
+		return nil, err
+	}
+
+	// A discoverable credential must return the user handle it was created with
+	if credential.Response.UserHandle != "" || session.UserID == 0 {
+		userHandle, err := utils.DecodeBase64URL(credential.Response.UserHandle)
+		if err != nil || utils.EncodeBase64URL(userHandle) != record.UserHandle {
+			return nil, fmt.Errorf("%w: user handle mismatch", utils.ErrWebAuthnVerification)
+		}
+	}
+
+	if err := utils.VerifyClientData(clientDataJSON, utils.WebAuthnCeremonyGet, session.Challenge, config.AppConfig.WebAuthnOrigins); err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := utils.DecodeBase64URL(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid authenticator data", utils.ErrWebAuthnVerification)
+	}
+	authData, err := utils.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+
+	signature, err := utils.DecodeBase64URL(credential.Response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", utils.ErrWebAuthnVerification)
+	}
+	publicKey, err := utils.ParseCOSEKey(record.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := publicKey.Verify(append(rawAuthData, clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	// Authenticators that don't implement a counter always report zero
+	if (authData.SignCount != 0 || record.SignCount != 0) && authData.SignCount <= record.SignCount {
+		return nil, ErrPasskeySignCount
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.WebAuthnCredential{}).
+		Where("id = ? AND sign_count = ?", record.ID, record.SignCount).
+		Updates(map[string]interface{}{
+			"sign_count":   authData.SignCount,
+			"backed_up":    authData.BackedUp(),
+			"last_used_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 && authData.SignCount != 0 {
+		// Another assertion with the same counter value won the race
+		return nil, ErrPasskeySignCount
+	}
+
+	record.SignCount = authData.SignCount
+	record.BackedUp = authData.BackedUp()
+	record.LastUsedAt = &now
+	return &record, nil
+}
+
+// checkAuthenticatorData checks the relying party and user presence flags
+func checkAuthenticatorData(authData *utils.AuthenticatorData, userVerification string) error {
+	if !authData.MatchesRPID(config.AppConfig.WebAuthnRPID) {
+		return fmt.Errorf("%w: relying party ID mismatch", utils.ErrWebAuthnVerification)
+	}
+	if !authData.UserPresent() {
+		return fmt.Errorf("%w: user not present", utils.ErrWebAuthnVerification)
+	}
+	if userVerification == UserVerificationRequired && !authData.UserVerified() {
+		return fmt.Errorf("%w: user not verified", utils.ErrWebAuthnVerification)
+	}
+	return nil
+}
+
+// decodeCredential decodes the credential ID and client data of a response
+func decodeCredential(credential *utils.PublicKeyCredential) ([]byte, []byte, error) {
+	if credential.Type != "public-key" {
+		return nil, nil, fmt.Errorf("%w: unsupported credential type", utils.ErrWebAuthnVerification)
+	}
+
+	rawID, err := utils.DecodeBase64URL(credential.ID)
+	if err != nil || len(rawID) == 0 {
+		return nil, nil, fmt.Errorf("%w: invalid credential ID", utils.ErrWebAuthnVerification)
+	}
+
+	clientDataJSON, err := utils.DecodeBase64URL(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid client data", utils.ErrWebAuthnVerification)
+	}
+
+	return rawID, clientDataJSON, nil
+}
+
+// credentialDescriptors lists credentials for allow and exclude lists
+func credentialDescriptors(credentials []models.WebAuthnCredential) []CredentialDescriptor {
+	descriptors := make([]CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptor := CredentialDescriptor{Type: "public-key", ID: credential.CredentialID}
+		if credential.Transports != "" {
+			descriptor.Transports = strings.Split(credential.Transports, ",")
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
+
+// formatAAGUID formats an authenticator AAGUID as a UUID
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	encoded := hex.EncodeToString(aaguid)
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:32]
+}
+
+// webAuthnTimeout is how long a ceremony may take
+func webAuthnTimeout() time.Duration {
+	return time.Duration(config.AppConfig.WebAuthnChallengeMinutes) * time.Minute
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/internal/webauthntest"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/models"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func setupWebAuthnTest(t *testing.T) (*WebAuthnService, *models.User) {
+	t.Helper()
+
+	setupTestDB(t, &models.WebAuthnCredential{})
+	config.AppConfig.WebAuthnRPID = testRPID
+	config.AppConfig.WebAuthnOrigins = []string{testOrigin}
+
+	return NewWebAuthnService(), createTestUser(t, "passkey@example.com")
+}
+
+// registerPasskey runs a registration ceremony and fails the test if it is
+// rejected
+func registerPasskey(t *testing.T, service *WebAuthnService, user *models.User, authenticator *webauthntest.Authenticator) *models.WebAuthnCredential {
+	t.Helper()
+
+	options, sessionToken, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+
+	record, err := service.FinishRegistration(user, sessionToken, "Laptop", authenticator.Create(options.Challenge, options.User.ID))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return record
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	authenticators := map[string]func(string, string) *webauthntest.Authenticator{
+		"ES256": webauthntest.NewES256,
+		"EdDSA": webauthntest.NewEdDSA,
+	}
+
+	for name, newAuthenticator := range authenticators {
+		t.Run(name, func(t *testing.T) {
+			service, user := setupWebAuthnTest(t)
+			authenticator := newAuthenticator(testRPID, testOrigin)
+
+			record := registerPasskey(t, service, user, authenticator)
+			if record.UserID != user.ID || record.Name != "Laptop" || record.Transports != "internal" {
+				t.Errorf("unexpected credential record %+v", record)
+			}
+
+			options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+			if err != nil {
+				t.Fatalf("BeginLogin() error = %v", err)
+			}
+			loggedIn, err := service.FinishLogin(sessionToken, authenticator.Get(options.Challenge))
+			if err != nil {
+				t.Fatalf("FinishLogin() error = %v", err)
+			}
+			if loggedIn.ID != user.ID {
+				t.Errorf("FinishLogin() user = %d, want %d", loggedIn.ID, user.ID)
+			}
+
+			options, sessionToken, err = service.BeginLogin(user, UserVerificationPreferred)
+			if err != nil {
+				t.Fatalf("BeginLogin() error = %v", err)
+			}
+			if err := service.VerifyAssertion(user.ID, sessionToken, authenticator.Get(options.Challenge)); err != nil {
+				t.Fatalf("VerifyAssertion() error = %v", err)
+			}
+
+			credentials, err := service.List(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(credentials) != 1 || credentials[0].SignCount != authenticator.SignCount {
+				t.Errorf("stored sign count = %d, want %d", credentials[0].SignCount, authenticator.SignCount)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationRejectsInvalidResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(authenticator *webauthntest.Authenticator, challenge *string)
+	}{
+		{"wrong origin", func(a *webauthntest.Authenticator, _ *string) { a.Origin = "https://evil.example" }},
+		{"wrong challenge", func(_ *webauthntest.Authenticator, challenge *string) { *challenge = "AAECAwQFBgcICQoLDA0ODw" }},
+		{"wrong rpId hash", func(a *webauthntest.Authenticator, _ *string) { a.RPID = "evil.example" }},
+		{"user not present", func(a *webauthntest.Authenticator, _ *string) { a.Flags = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, user := setupWebAuthnTest(t)
+			authenticator := webauthntest.NewES256(testRPID, testOrigin)
+
+			options, sessionToken, err := service.BeginRegistration(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			challenge := options.Challenge
+			tt.tamper(authenticator, &challenge)
+
+			_, err = service.FinishRegistration(user, sessionToken, "", authenticator.Create(challenge, options.User.ID))
+			if !errors.Is(err, utils.ErrWebAuthnVerification) {
+				t.Errorf("FinishRegistration() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationRejectsReplayedSession(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+
+	options, sessionToken, err := service.BeginRegistration(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.FinishRegistration(user, sessionToken, "", webauthntest.NewES256(testRPID, testOrigin).Create(options.Challenge, options.User.ID)); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+
+	_, err = service.FinishRegistration(user, sessionToken, "", webauthntest.NewES256(testRPID, testOrigin).Create(options.Challenge, options.User.ID))
+	if !errors.Is(err, ErrInvalidWebAuthnSession) {
+		t.Errorf("replayed FinishRegistration() error = %v, want %v", err, ErrInvalidWebAuthnSession)
+	}
+}
+
+func TestFinishRegistrationRejectsOtherUsersSession(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	other := createTestUser(t, "other@example.com")
+
+	options, sessionToken, err := service.BeginRegistration(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = service.FinishRegistration(user, sessionToken, "", webauthntest.NewES256(testRPID, testOrigin).Create(options.Challenge, options.User.ID))
+	if !errors.Is(err, ErrInvalidWebAuthnSession) {
+		t.Errorf("FinishRegistration() error = %v, want %v", err, ErrInvalidWebAuthnSession)
+	}
+}
+
+func TestFinishLoginRejectsInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(authenticator *webauthntest.Authenticator, challenge *string)
+	}{
+		{"wrong origin", func(a *webauthntest.Authenticator, _ *string) { a.Origin = "https://evil.example" }},
+		{"wrong challenge", func(_ *webauthntest.Authenticator, challenge *string) { *challenge = "AAECAwQFBgcICQoLDA0ODw" }},
+		{"wrong rpId hash", func(a *webauthntest.Authenticator, _ *string) { a.RPID = "evil.example" }},
+		{"user not verified", func(a *webauthntest.Authenticator, _ *string) { a.Flags = webauthntest.FlagUserPresent }},
+		{"wrong user handle", func(a *webauthntest.Authenticator, _ *string) { a.UserHandle = []byte("someone else") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, user := setupWebAuthnTest(t)
+			authenticator := webauthntest.NewES256(testRPID, testOrigin)
+			registerPasskey(t, service, user, authenticator)
+
+			options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			challenge := options.Challenge
+			tt.tamper(authenticator, &challenge)
+
+			_, err = service.FinishLogin(sessionToken, authenticator.Get(challenge))
+			if !errors.Is(err, utils.ErrWebAuthnVerification) {
+				t.Errorf("FinishLogin() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+			}
+		})
+	}
+}
+
+func TestFinishLoginRejectsInvalidSignature(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	authenticator := webauthntest.NewEdDSA(testRPID, testOrigin)
+	registerPasskey(t, service, user, authenticator)
+
+	options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign with a different key for the same credential ID
+	impostor := webauthntest.NewEdDSA(testRPID, testOrigin)
+	impostor.CredentialID = authenticator.CredentialID
+	impostor.UserHandle = authenticator.UserHandle
+	impostor.SignCount = authenticator.SignCount
+
+	_, err = service.FinishLogin(sessionToken, impostor.Get(options.Challenge))
+	if !errors.Is(err, utils.ErrWebAuthnVerification) {
+		t.Errorf("FinishLogin() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+	}
+}
+
+func TestFinishLoginRejectsReplayedSession(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	authenticator := webauthntest.NewES256(testRPID, testOrigin)
+	registerPasskey(t, service, user, authenticator)
+
+	options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.FinishLogin(sessionToken, authenticator.Get(options.Challenge)); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	_, err = service.FinishLogin(sessionToken, authenticator.Get(options.Challenge))
+	if !errors.Is(err, ErrInvalidWebAuthnSession) {
+		t.Errorf("replayed FinishLogin() error = %v, want %v", err, ErrInvalidWebAuthnSession)
+	}
+}
+
+func TestFinishLoginRejectsNonIncreasingSignCount(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	authenticator := webauthntest.NewES256(testRPID, testOrigin)
+	authenticator.SignCount = 10
+	registerPasskey(t, service, user, authenticator)
+
+	for _, signCount := range []uint32{9, 10} {
+		options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Get increments the counter before signing
+		authenticator.SignCount = signCount - 1
+		_, err = service.FinishLogin(sessionToken, authenticator.Get(options.Challenge))
+		if !errors.Is(err, ErrPasskeySignCount) {
+			t.Errorf("FinishLogin() with sign count %d error = %v, want %v", signCount, err, ErrPasskeySignCount)
+		}
+	}
+
+	options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.SignCount = 10
+	if _, err := service.FinishLogin(sessionToken, authenticator.Get(options.Challenge)); err != nil {
+		t.Errorf("FinishLogin() with sign count 11 error = %v", err)
+	}
+}
+
+func TestFinishLoginAllowsZeroSignCount(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	authenticator := webauthntest.NewES256(testRPID, testOrigin)
+	registerPasskey(t, service, user, authenticator)
+
+	// Authenticators without a counter always report zero
+	for i := 0; i < 2; i++ {
+		options, sessionToken, err := service.BeginLogin(nil, UserVerificationRequired)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Get increments the counter, wrapping it round to zero
+		authenticator.SignCount = ^uint32(0)
+		if _, err := service.FinishLogin(sessionToken, authenticator.Get(options.Challenge)); err != nil {
+			t.Errorf("FinishLogin() with zero sign count error = %v", err)
+		}
+	}
+}
+
+func TestVerifyAssertionRejectsOtherUsersSession(t *testing.T) {
+	service, user := setupWebAuthnTest(t)
+	authenticator := webauthntest.NewES256(testRPID, testOrigin)
+	registerPasskey(t, service, user, authenticator)
+
+	options, sessionToken, err := service.BeginLogin(user, UserVerificationPreferred)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.VerifyAssertion(user.ID+1, sessionToken, authenticator.Get(options.Challenge))
+	if !errors.Is(err, ErrInvalidWebAuthnSession) {
+		t.Errorf("VerifyAssertion() error = %v, want %v", err, ErrInvalidWebAuthnSession)
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want interface{}
+	}{
+		{"small integer", "0a", int64(10)},
+		{"one byte integer", "1819", int64(25)},
+		{"two byte integer", "1903e8", int64(1000)},
+		{"negative integer", "26", int64(-7)},
+		{"large negative integer", "390100", int64(-257)},
+		{"byte string", "4401020304", []byte{1, 2, 3, 4}},
+		{"text string", "6449455446", "IETF"},
+		{"array", "83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"map", "a2616101200a", map[interface{}]interface{}{"a": int64(1), int64(-1): int64(10)}},
+		{"booleans and null", "83f4f5f6", []interface{}{false, true, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := DecodeCBOR(mustHex(t, tt.hex))
+			if err != nil {
+				t.Fatalf("DecodeCBOR() error = %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("DecodeCBOR() left %d bytes", len(rest))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeCBOR() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORReturnsRemainingBytes(t *testing.T) {
+	_, rest, err := DecodeCBOR(mustHex(t, "01ff"))
+	if err != nil {
+		t.Fatalf("DecodeCBOR() error = %v", err)
+	}
+	if !bytes.Equal(rest, []byte{0xff}) {
+		t.Errorf("DecodeCBOR() rest = %x, want ff", rest)
+	}
+}
+
+func TestDecodeCBORRejectsInvalidData(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"empty", ""},
+		{"truncated argument", "19ff"},
+		{"string longer than data", "45010203"},
+		{"array longer than data", "8401"},
+		{"map longer than data", "a301"},
+		{"duplicate map key", "a201020103"},
+		{"byte string map key", "a1410100"},
+		{"indefinite length", "9f01ff"},
+		{"tag", "c100"},
+		{"float", "f93c00"},
+		{"integer overflow", "1bffffffffffffffff"},
+		{"nesting too deep", strings.Repeat("81", maxCBORDepth+2) + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeCBOR(mustHex(t, tt.hex)); !errors.Is(err, ErrInvalidCBOR) {
+				t.Errorf("DecodeCBOR() error = %v, want %v", err, ErrInvalidCBOR)
+			}
+		})
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -2,14 +2,30 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
 // GenerateRandomToken generates a random token
 func GenerateRandomToken(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
+	bytes, err := GenerateRandomBytes(length)
+	if err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// GenerateRandomBytes returns length cryptographically random bytes
+func GenerateRandomBytes(length int) ([]byte, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, err
+	}
+	return bytes, nil
+}
+
+// HashToken returns the hex-encoded SHA-256 digest of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
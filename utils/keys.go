@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+)
+
+// Key is a JWT signing or verification key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	// SignKey is the secret or private key; nil for verification-only keys
+	SignKey interface{}
+	// VerifyKey is the secret or public key
+	VerifyKey interface{}
+}
+
+// JWK is a single JSON Web Key as published in the JWKS document
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is the JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	signingKey       *Key
+	verificationKeys = map[string]*Key{}
+)
+
+// LoadSigningKeys loads the active signing key and any additional verification
+// keys that are still trusted during a key rotation
+func LoadSigningKeys() error {
+	cfg := config.AppConfig
+
+	key, err := loadSigningKey(cfg.JWTAlgorithm, cfg.JWTKeyID, cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*Key{key.ID: key}
+
+	for _, entry := range strings.Split(cfg.JWTPublicKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path, found := strings.Cut(entry, "=")
+		if !found || kid == "" || path == "" {
+			return fmt.Errorf("invalid JWT_PUBLIC_KEYS entry %q, expected kid=path", entry)
+		}
+		if _, exists := keys[kid]; exists {
+			return fmt.Errorf("duplicate JWT key id %q", kid)
+		}
+
+		publicKey, err := loadPublicKey(path)
+		if err != nil {
+			return fmt.Errorf("failed to load public key %q: %w", kid, err)
+		}
+
+		algorithm, err := algorithmForKey(publicKey)
+		if err != nil {
+			return err
+		}
+
+		keys[kid] = &Key{ID: kid, Algorithm: algorithm, VerifyKey: publicKey}
+	}
+
+	signingKey = key
+	verificationKeys = keys
+
+	return nil
+}
+
+// SigningKey returns the key used to sign new tokens
+func SigningKey() *Key {
+	return signingKey
+}
+
+// VerificationKey returns the trusted key with the given kid
+func VerificationKey(kid string) (*Key, bool) {
+	key, ok := verificationKeys[kid]
+	return key, ok
+}
+
+// PublicJWKS returns the public verification keys as a JWKS document.
+// Symmetric keys are never published.
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range verificationKeys {
+		jwk, ok := toJWK(key)
+		if ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+// loadSigningKey builds the active signing key for the configured algorithm
+func loadSigningKey(algorithm, kid, privateKeyFile string) (*Key, error) {
+	if jwt.GetSigningMethod(algorithm) == nil {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		secret := []byte(config.AppConfig.JWTSecret)
+		return &Key{ID: kid, Algorithm: algorithm, SignKey: secret, VerifyKey: secret}, nil
+	}
+
+	var privateKey crypto.Signer
+	if privateKeyFile == "" {
+		// A generated key differs per instance and per restart, so every
+		// other instance rejects its tokens. Only allow it when asked to.
+		if !config.AppConfig.JWTAllowEphemeralKey {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s; set JWT_ALLOW_EPHEMERAL_KEY=true to generate a throwaway key for development", algorithm)
+		}
+		log.Printf("Warning: JWT_PRIVATE_KEY_FILE not set, generating an ephemeral %s key; tokens will not survive a restart", algorithm)
+
+		generated, err := generatePrivateKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = generated
+	} else {
+		loaded, err := loadPrivateKey(privateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT private key: %w", err)
+		}
+		privateKey = loaded
+	}
+
+	keyAlgorithm, err := algorithmForKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if keyAlgorithm != algorithm {
+		return nil, fmt.Errorf("JWT private key is a %s key but JWT_ALGORITHM is %s", keyAlgorithm, algorithm)
+	}
+
+	return &Key{ID: kid, Algorithm: algorithm, SignKey: privateKey, VerifyKey: privateKey.Public()}, nil
+}
+
+func generatePrivateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+	return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot be used for signing")
+	}
+	return signer, nil
+}
+
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return block, nil
+}
+
+// algorithmForKey returns the JWT algorithm used with a public key
+func algorithmForKey(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256.Alg(), nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", errors.New("only P-256 ECDSA keys are supported")
+		}
+		return jwt.SigningMethodES256.Alg(), nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA.Alg(), nil
+	}
+	return "", fmt.Errorf("unsupported public key type %T", publicKey)
+}
+
+func toJWK(key *Key) (JWK, bool) {
+	jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+
+	switch publicKey := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64URL(publicKey.N.Bytes())
+		jwk.E = base64URL(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		ecdhKey, err := publicKey.ECDH()
+		if err != nil {
+			return JWK{}, false
+		}
+		// Uncompressed point: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		jwk.KeyType = "EC"
+		jwk.Curve = publicKey.Curve.Params().Name
+		jwk.X = base64URL(point[1 : 1+size])
+		jwk.Y = base64URL(point[1+size:])
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64URL(publicKey)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// PublicKey decodes the RSA, P-256 or Ed25519 public key of a JWK
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if publicKey.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key is too small")
+		}
+		return publicKey, nil
+	case "EC":
+		if j.Curve != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("unsupported curve %q", j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("invalid EC key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("invalid EC key")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{0x04}, x...), y...))
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || j.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.KeyType)
+}
+
+func base64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,26 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+func TestLoadSigningKeysRequiresPrivateKeyFile(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	config.AppConfig = &config.Config{JWTAlgorithm: "ES256", JWTKeyID: "test"}
+	if err := utils.LoadSigningKeys(); err == nil {
+		t.Fatal("asymmetric algorithm without a private key file was accepted")
+	}
+
+	config.AppConfig.JWTAllowEphemeralKey = true
+	if err := utils.LoadSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if key := utils.SigningKey(); key == nil || key.Algorithm != "ES256" {
+		t.Fatalf("got signing key %+v, want an ES256 key", key)
+	}
+}
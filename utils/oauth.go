@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenUseOAuthState marks tokens carrying the state of an external
+// OAuth2/OIDC sign-in between its authorize and callback requests
+const TokenUseOAuthState = "oauth_state"
+
+// OAuthStateClaims hold the values needed to complete an authorization code
+// flow with an external provider
+type OAuthStateClaims struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// UserID is set when linking the identity to an existing account
+	UserID   uint   `json:"user_id,omitempty"`
+	TokenUse string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// GenerateOAuthStateToken signs the state of an external sign-in
+func GenerateOAuthStateToken(claims OAuthStateClaims, ttl time.Duration) (string, error) {
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.TokenUse = TokenUseOAuthState
+	claims.ID = tokenID
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+
+	return SignClaims(&claims)
+}
+
+// OIDCUserClaims are the standard OpenID Connect claims describing a user
+type OIDCUserClaims struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	UpdatedAt     int64  `json:"updated_at,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token
+type IDTokenClaims struct {
+	Nonce           string           `json:"nonce,omitempty"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty string           `json:"azp,omitempty"`
+	OIDCUserClaims
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken signs an ID token. Issuer, subject and audience must be
+// set by the caller.
+func GenerateIDToken(claims IDTokenClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+
+	return SignClaims(&claims)
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier
+func GenerateCodeVerifier() (string, error) {
+	verifier, err := GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase64URL(verifier), nil
+}
+
+// userCodeAlphabet leaves out vowels and look-alike characters so user codes
+// are easy to read and never spell words
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// GenerateUserCode returns a random device flow user code formatted as
+// XXXX-XXXX
+func GenerateUserCode() (string, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code[:4]) + "-" + string(code[4:]), nil
+}
+
+// NormalizeUserCode strips formatting so user codes can be typed loosely
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			return r
+		}
+		return -1
+	}, strings.ToUpper(code))
+}
+
+// PKCE code challenge methods (RFC 7636 §4.2)
+const (
+	CodeChallengeMethodS256  = "S256"
+	CodeChallengeMethodPlain = "plain"
+)
+
+// CodeChallengeS256 derives the S256 PKCE code challenge of a verifier
+func CodeChallengeS256(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return EncodeBase64URL(hash[:])
+}
+
+// VerifyCodeChallenge reports whether a PKCE code verifier matches the
+// challenge sent with the authorization request
+func VerifyCodeChallenge(method, challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	if method == CodeChallengeMethodS256 {
+		verifier = CodeChallengeS256(verifier)
+	}
+	return subtle.ConstantTimeCompare([]byte(verifier), []byte(challenge)) == 1
+}
@@ -0,0 +1,38 @@
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// TestVerifyCodeChallenge uses the example from RFC 7636 Appendix B
+func TestVerifyCodeChallenge(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := utils.CodeChallengeS256(verifier); got != challenge {
+		t.Fatalf("CodeChallengeS256() = %q, want %q", got, challenge)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"S256", utils.CodeChallengeMethodS256, challenge, verifier, true},
+		{"S256 with the challenge as verifier", utils.CodeChallengeMethodS256, challenge, challenge, false},
+		{"S256 with another verifier", utils.CodeChallengeMethodS256, challenge, verifier[1:], false},
+		{"plain", utils.CodeChallengeMethodPlain, verifier, verifier, true},
+		{"plain with another verifier", utils.CodeChallengeMethodPlain, verifier, verifier[1:], false},
+		{"plain with the S256 challenge", utils.CodeChallengeMethodPlain, verifier, challenge, false},
+		{"missing verifier", utils.CodeChallengeMethodPlain, "", "", false},
+	}
+	for _, tt := range tests {
+		if got := utils.VerifyCodeChallenge(tt.method, tt.challenge, tt.verifier); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
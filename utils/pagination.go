@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Pagination struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// GetPagination reads the page and per_page query parameters
+func GetPagination(c *gin.Context) *Pagination {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(c.Query("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return &Pagination{Page: page, PerPage: perPage}
+}
+
+// Offset returns the number of records to skip for the current page
+func (p *Pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// SetTotal records the total number of records and derives the page count
+func (p *Pagination) SetTotal(total int64) {
+	p.Total = total
+	p.TotalPages = int((total + int64(p.PerPage) - 1) / int64(p.PerPage))
+}
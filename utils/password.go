@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords into PHC-formatted strings
+// ($id$params$salt$hash) so hashes from several algorithms can coexist
+type PasswordHasher interface {
+	// ID is the algorithm identifier at the start of the hashes it produces
+	ID() string
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with other parameters
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	passwordHasher  PasswordHasher = NewArgon2idHasher(DefaultArgon2idParams())
+	passwordHashers                = map[string]PasswordHasher{}
+)
+
+func init() {
+	RegisterPasswordHasher(passwordHasher)
+	RegisterPasswordHasher(NewBcryptHasher(bcrypt.DefaultCost))
+}
+
+// RegisterPasswordHasher makes a hasher available for verifying existing hashes
+func RegisterPasswordHasher(hasher PasswordHasher) {
+	passwordHashers[hasher.ID()] = hasher
+}
+
+// LoadPasswordHasher configures the hasher used for new passwords
+func LoadPasswordHasher() error {
+	cfg := config.AppConfig
+
+	var hasher PasswordHasher
+	switch strings.ToLower(cfg.PasswordHashAlgorithm) {
+	case "argon2id":
+		if cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 || cfg.Argon2Iterations < 1 ||
+			cfg.Argon2MemoryKiB < 8*cfg.Argon2Parallelism {
+			return errors.New("invalid argon2id parameters")
+		}
+
+		params := DefaultArgon2idParams()
+		params.Memory = uint32(cfg.Argon2MemoryKiB)
+		params.Iterations = uint32(cfg.Argon2Iterations)
+		params.Parallelism = uint8(cfg.Argon2Parallelism)
+		hasher = NewArgon2idHasher(params)
+	case "bcrypt":
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("invalid BCRYPT_COST %d", cfg.BcryptCost)
+		}
+		hasher = NewBcryptHasher(cfg.BcryptCost)
+	default:
+		return fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM %q", cfg.PasswordHashAlgorithm)
+	}
+
+	passwordHasher = hasher
+	RegisterPasswordHasher(hasher)
+	return nil
+}
+
+// MaxPasswordBytes returns the longest password in bytes the configured
+// hasher can hash, or 0 when it has no limit
+func MaxPasswordBytes() int {
+	if limited, ok := passwordHasher.(interface{ MaxPasswordBytes() int }); ok {
+		return limited.MaxPasswordBytes()
+	}
+	return 0
+}
+
+// HashPassword hashes a password with the configured hasher
+func HashPassword(password string) (string, error) {
+	return passwordHasher.Hash(password)
+}
+
+// VerifyPassword checks a password against a hash produced by any registered
+// hasher. needsRehash is true when the hash should be upgraded to the
+// configured algorithm or parameters.
+func VerifyPassword(password, encoded string) (ok bool, needsRehash bool) {
+	hasher, found := passwordHashers[passwordHashID(encoded)]
+	if !found {
+		return false, false
+	}
+
+	ok, err := hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false
+	}
+
+	return true, hasher.ID() != passwordHasher.ID() || passwordHasher.NeedsRehash(encoded)
+}
+
+// passwordHashID extracts the algorithm identifier from a PHC-formatted hash
+func passwordHashID(encoded string) string {
+	parts := strings.SplitN(encoded, "$", 3)
+	if len(parts) < 3 || parts[0] != "" {
+		return ""
+	}
+
+	switch parts[1] {
+	case "2a", "2b", "2y":
+		// bcrypt's modular crypt format uses its version as the identifier
+		return "bcrypt"
+	}
+	return parts[1]
+}
+
+// Argon2idParams are the cost parameters of an Argon2id hash
+type Argon2idParams struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follows the OWASP recommendation for Argon2id
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher creates a hasher producing $argon2id$ hashes
+func NewArgon2idHasher(params Argon2idParams) PasswordHasher {
+	return &argon2idHasher{params: params}
+}
+
+func (h *argon2idHasher) ID() string {
+	return "argon2id"
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params != h.params
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownPasswordHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// bcryptMaxPasswordBytes is the longest password bcrypt accepts
+const bcryptMaxPasswordBytes = 72
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a hasher producing $2a$ bcrypt hashes. bcrypt only
+// uses the first 72 bytes of a password and rejects longer ones.
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) ID() string {
+	return "bcrypt"
+}
+
+// MaxPasswordBytes returns the longest password in bytes bcrypt can hash
+func (h *bcryptHasher) MaxPasswordBytes() int {
+	return bcryptMaxPasswordBytes
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
@@ -0,0 +1,132 @@
+package utils_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useTestHasher configures a cheap hasher for new passwords
+func useTestHasher(t *testing.T, algorithm string) {
+	t.Helper()
+
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	config.AppConfig = &config.Config{
+		PasswordHashAlgorithm: algorithm,
+		Argon2MemoryKiB:       1024,
+		Argon2Iterations:      1,
+		Argon2Parallelism:     1,
+		BcryptCost:            bcrypt.MinCost,
+	}
+	if err := utils.LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	useTestHasher(t, "argon2id")
+
+	hash, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	phc := regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !phc.MatchString(hash) {
+		t.Fatalf("hash %q is not a PHC argon2id string", hash)
+	}
+
+	if other, _ := utils.HashPassword("correct horse"); other == hash {
+		t.Fatal("hashing the same password twice gave the same salt")
+	}
+
+	if ok, needsRehash := utils.VerifyPassword("correct horse", hash); !ok || needsRehash {
+		t.Fatalf("got ok %v, needsRehash %v, want true, false", ok, needsRehash)
+	}
+	if ok, _ := utils.VerifyPassword("wrong horse", hash); ok {
+		t.Fatal("wrong password was accepted")
+	}
+}
+
+// TestArgon2idVerifyEncoded checks hashes built outside the hasher, so the
+// PHC decoding is exercised independently of the encoding
+func TestArgon2idVerifyEncoded(t *testing.T) {
+	useTestHasher(t, "argon2id")
+
+	salt := []byte("somesaltsomesalt")
+	key := argon2.IDKey([]byte("password"), salt, 2, 4096, 1, 32)
+	encode := func(version, params string) string {
+		return fmt.Sprintf("$argon2id$v=%s$%s$%s$%s", version, params,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	}
+
+	if ok, needsRehash := utils.VerifyPassword("password", encode("19", "m=4096,t=2,p=1")); !ok || !needsRehash {
+		t.Fatalf("got ok %v, needsRehash %v, want true, true", ok, needsRehash)
+	}
+
+	for _, encoded := range []string{
+		encode("19", "m=4096,t=3,p=1"),
+		encode("16", "m=4096,t=2,p=1"),
+		encode("19", "m=4096,t=2"),
+		"$argon2id$v=19$m=4096,t=2,p=1$not base64$" + base64.RawStdEncoding.EncodeToString(key),
+		"$argon2i$v=19$m=4096,t=2,p=1$c29tZXNhbHQ$c29tZXNhbHQ",
+	} {
+		if ok, _ := utils.VerifyPassword("password", encoded); ok {
+			t.Errorf("%q was accepted", encoded)
+		}
+	}
+}
+
+// TestVerifyPasswordNeedsRehash checks the signal login uses to upgrade
+// hashes to the configured algorithm and parameters
+func TestVerifyPasswordNeedsRehash(t *testing.T) {
+	useTestHasher(t, "argon2id")
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker, err := utils.NewArgon2idHasher(utils.Argon2idParams{
+		Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	}).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		hash            string
+		wantNeedsRehash bool
+	}{
+		{"bcrypt", string(legacy), true},
+		{"weaker argon2id", weaker, true},
+		{"current", current, false},
+	}
+	for _, tt := range tests {
+		ok, needsRehash := utils.VerifyPassword("correct horse", tt.hash)
+		if !ok || needsRehash != tt.wantNeedsRehash {
+			t.Errorf("%s: got ok %v, needsRehash %v, want true, %v", tt.name, ok, needsRehash, tt.wantNeedsRehash)
+		}
+		if ok, needsRehash := utils.VerifyPassword("wrong horse", tt.hash); ok || needsRehash {
+			t.Errorf("%s: wrong password gave ok %v, needsRehash %v", tt.name, ok, needsRehash)
+		}
+	}
+
+	// Switching algorithms keeps old hashes working until they are upgraded
+	useTestHasher(t, "bcrypt")
+	if ok, needsRehash := utils.VerifyPassword("correct horse", current); !ok || !needsRehash {
+		t.Fatalf("argon2id hash after switching to bcrypt: got ok %v, needsRehash %v, want true, true", ok, needsRehash)
+	}
+}
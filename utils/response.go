@@ -26,3 +26,13 @@ func ErrorResponse(c *gin.Context, statusCode int, message string, err string) {
 		Error:   err,
 	})
 }
+
+// ErrorResponseWithData returns an error response with details in data
+func ErrorResponseWithData(c *gin.Context, statusCode int, message string, err string, data interface{}) {
+	c.JSON(statusCode, Response{
+		Success: false,
+		Message: message,
+		Data:    data,
+		Error:   err,
+	})
+}
@@ -8,50 +8,122 @@ import (
 	"github.com/mamatqurtifa/golang-auth-api-boilerplate/config"
 )
 
+// Token use values distinguish access tokens from other tokens we sign
+const (
+	TokenUseAccess       = "access"
+	TokenUseMFAChallenge = "mfa_challenge"
+)
+
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    uint     `json:"user_id"`
+	Email     string   `json:"email"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	TokenUse  string   `json:"token_use"`
+	// TokenVersion must match the user's current token version
+	TokenVersion uint `json:"ver"`
+	// Scope and ClientID are set on tokens issued to OAuth clients
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	// OrganizationID and OrganizationRole describe the session's active
+	// organization, if any
+	OrganizationID   uint   `json:"org_id,omitempty"`
+	OrganizationRole string `json:"org_role,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID uint, email string) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWTExpirationHours) * time.Hour)
+// GenerateToken generates a JWT access token carrying the given claims.
+// The token ID and time-based registered claims are filled in here.
+func GenerateToken(claims Claims) (string, error) {
+	claims.TokenUse = TokenUseAccess
+	return generateToken(claims, AccessTokenTTL())
+}
 
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// GenerateMFAChallengeToken generates a short-lived token proving the password
+// step of a two-step login succeeded. It cannot be used as an access token.
+func GenerateMFAChallengeToken(userID uint, email string) (string, error) {
+	claims := Claims{
+		UserID:   userID,
+		Email:    email,
+		TokenUse: TokenUseMFAChallenge,
 	}
+	return generateToken(claims, time.Duration(config.AppConfig.MFAChallengeMinutes)*time.Minute)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWTSecret))
-	if err != nil {
-		return "", err
+// generateToken fills in the token ID, unless one was given, and time-based
+// claims and signs the token
+func generateToken(claims Claims, ttl time.Duration) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+
+	// Callers that need to keep track of the token may pick its ID
+	if claims.ID == "" {
+		tokenID, err := GenerateRandomToken(16)
+		if err != nil {
+			return "", err
+		}
+		claims.ID = tokenID
+	}
+
+	claims.ExpiresAt = jwt.NewNumericDate(expirationTime)
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	claims.NotBefore = jwt.NewNumericDate(time.Now())
+
+	return SignClaims(&claims)
+}
+
+// SignClaims signs arbitrary claims with the active signing key
+func SignClaims(claims jwt.Claims) (string, error) {
+	key := SigningKey()
+	if key == nil {
+		return "", errors.New("signing keys not loaded")
 	}
 
-	return tokenString, nil
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.SignKey)
 }
 
 // ValidateToken validates a JWT token and returns the claims
 func ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
+	if err := ParseClaims(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// ParseClaims verifies a token against the trusted keys and decodes its claims.
+// The key is selected by the kid header and must match the token's algorithm.
+func ParseClaims(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) error {
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.AppConfig.JWTSecret), nil
-	})
+		key, ok := SigningKey(), SigningKey() != nil
+		if kid, found := token.Header["kid"].(string); found {
+			key, ok = VerificationKey(kid)
+		}
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing algorithm")
+		}
+		return key.VerifyKey, nil
+	}, options...)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return errors.New("invalid token")
 	}
 
-	return claims, nil
+	return nil
+}
+
+// AccessTokenTTL returns the configured lifetime of access tokens
+func AccessTokenTTL() time.Duration {
+	return time.Duration(config.AppConfig.AccessTokenMinutes) * time.Minute
 }
@@ -0,0 +1,64 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+// rfc6238Secret is the SHA-1 test key "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestValidateTOTPVectors checks the SHA-1 test vectors from RFC 6238
+// Appendix B, truncated to the six digits authenticator apps show
+func TestValidateTOTPVectors(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		step, ok := utils.ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		if !ok {
+			t.Errorf("code %s was rejected at %d", v.code, v.unix)
+			continue
+		}
+		if step != v.unix/30 {
+			t.Errorf("code %s matched step %d, want %d", v.code, step, v.unix/30)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	const code = "081804" // step 37037036
+	at := func(unix int64) time.Time { return time.Unix(unix, 0) }
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"same step", at(1111111109), true},
+		{"one step late", at(1111111109 + 30), true},
+		{"one step early", at(1111111109 - 30), true},
+		{"two steps late", at(1111111109 + 60), false},
+	}
+	for _, tt := range tests {
+		if _, ok := utils.ValidateTOTP(rfc6238Secret, code, tt.now); ok != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"081805", "81804", "0818040", ""} {
+		if _, ok := utils.ValidateTOTP(rfc6238Secret, bad, at(1111111109)); ok {
+			t.Errorf("code %q was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenUseWebAuthn marks tokens carrying the state of a WebAuthn ceremony
+const TokenUseWebAuthn = "webauthn"
+
+// WebAuthn ceremony types as reported in client data
+const (
+	WebAuthnCeremonyCreate = "webauthn.create"
+	WebAuthnCeremonyGet    = "webauthn.get"
+)
+
+// COSE algorithm identifiers supported for credential public keys
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// Authenticator data flags
+const (
+	authDataFlagUserPresent    = 0x01
+	authDataFlagUserVerified   = 0x04
+	authDataFlagBackupEligible = 0x08
+	authDataFlagBackedUp       = 0x10
+	authDataFlagAttestedData   = 0x40
+	authDataFlagExtensionData  = 0x80
+)
+
+// minRSAKeyBits is the smallest RSA credential key accepted
+const minRSAKeyBits = 2048
+
+var ErrWebAuthnVerification = errors.New("webauthn_verification_failed")
+
+// PublicKeyCredential is the JSON form of a credential returned by
+// navigator.credentials.create() or get(), with binary fields base64url encoded
+type PublicKeyCredential struct {
+	ID       string                `json:"id" binding:"required"`
+	RawID    string                `json:"rawId"`
+	Type     string                `json:"type" binding:"required"`
+	Response AuthenticatorResponse `json:"response" binding:"required"`
+}
+
+// AuthenticatorResponse holds the fields of an attestation or assertion response
+type AuthenticatorResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject,omitempty"`
+	AuthenticatorData string   `json:"authenticatorData,omitempty"`
+	Signature         string   `json:"signature,omitempty"`
+	UserHandle        string   `json:"userHandle,omitempty"`
+	Transports        []string `json:"transports,omitempty"`
+}
+
+// WebAuthnSessionClaims carry the challenge of a ceremony between its begin
+// and finish requests so no server-side state is needed
+type WebAuthnSessionClaims struct {
+	UserID uint `json:"user_id,omitempty"`
+	// UserHandle is the WebAuthn user ID offered during registration
+	UserHandle       string `json:"user_handle,omitempty"`
+	Ceremony         string `json:"ceremony"`
+	Challenge        string `json:"challenge"`
+	UserVerification string `json:"uv"`
+	TokenUse         string `json:"token_use"`
+	jwt.RegisteredClaims
+}
+
+// GenerateWebAuthnSessionToken signs the state of a WebAuthn ceremony
+func GenerateWebAuthnSessionToken(claims WebAuthnSessionClaims, ttl time.Duration) (string, error) {
+	tokenID, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.TokenUse = TokenUseWebAuthn
+	claims.ID = tokenID
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+
+	return SignClaims(&claims)
+}
+
+// DecodeBase64URL decodes base64url data with or without padding. Standard
+// base64 is accepted too since some clients send it.
+func DecodeBase64URL(value string) ([]byte, error) {
+	value = strings.TrimRight(value, "=")
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// EncodeBase64URL encodes data as unpadded base64url
+func EncodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// collectedClientData is the client data the browser signs over
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// VerifyClientData checks the ceremony type, challenge and origin of the
+// client data JSON
+func VerifyClientData(clientDataJSON []byte, ceremony, challenge string, origins []string) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return fmt.Errorf("%w: invalid client data", ErrWebAuthnVerification)
+	}
+
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: unexpected ceremony type", ErrWebAuthnVerification)
+	}
+
+	got, err := DecodeBase64URL(clientData.Challenge)
+	if err != nil {
+		return fmt.Errorf("%w: invalid challenge", ErrWebAuthnVerification)
+	}
+	want, err := DecodeBase64URL(challenge)
+	if err != nil || !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: challenge mismatch", ErrWebAuthnVerification)
+	}
+
+	for _, origin := range origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin %q is not allowed", ErrWebAuthnVerification, clientData.Origin)
+}
+
+// AuthenticatorData is the parsed authenticator data of a WebAuthn response
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// Attested credential data, present only during registration
+	AAGUID              []byte
+	CredentialID        []byte
+	CredentialPublicKey []byte
+}
+
+// MatchesRPID reports whether the data was produced for the relying party ID
+func (a *AuthenticatorData) MatchesRPID(rpID string) bool {
+	hash := sha256.Sum256([]byte(rpID))
+	return bytes.Equal(a.RPIDHash, hash[:])
+}
+
+// UserPresent reports whether the user touched the authenticator
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&authDataFlagUserPresent != 0
+}
+
+// UserVerified reports whether the authenticator verified the user, e.g.
+// with a PIN or biometrics
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&authDataFlagUserVerified != 0
+}
+
+// BackupEligible reports whether the credential can be synced between devices
+func (a *AuthenticatorData) BackupEligible() bool {
+	return a.Flags&authDataFlagBackupEligible != 0
+}
+
+// BackedUp reports whether the credential is currently synced
+func (a *AuthenticatorData) BackedUp() bool {
+	return a.Flags&authDataFlagBackedUp != 0
+}
+
+// ParseAuthenticatorData parses raw authenticator data
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrWebAuthnVerification)
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&authDataFlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrWebAuthnVerification)
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, fmt.Errorf("%w: credential ID too short", ErrWebAuthnVerification)
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid credential public key", ErrWebAuthnVerification)
+		}
+		authData.CredentialPublicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if authData.Flags&authDataFlagExtensionData != 0 {
+		_, remaining, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid extension data", ErrWebAuthnVerification)
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrWebAuthnVerification)
+	}
+
+	return authData, nil
+}
+
+// ParseAttestationObject extracts the authenticator data and attestation
+// statement format from an attestation object
+func ParseAttestationObject(data []byte) (*AuthenticatorData, string, error) {
+	decoded, rest, err := DecodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, "", fmt.Errorf("%w: invalid attestation object", ErrWebAuthnVerification)
+	}
+
+	object, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("%w: invalid attestation object", ErrWebAuthnVerification)
+	}
+
+	format, _ := object["fmt"].(string)
+	rawAuthData, ok := object["authData"].([]byte)
+	if !ok || format == "" {
+		return nil, "", fmt.Errorf("%w: invalid attestation object", ErrWebAuthnVerification)
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return authData, format, nil
+}
+
+// COSEKey is a credential public key decoded from its COSE encoding
+type COSEKey struct {
+	Algorithm int64
+	PublicKey crypto.PublicKey
+}
+
+// ParseCOSEKey decodes an ES256, RS256 or EdDSA COSE public key
+func ParseCOSEKey(data []byte) (*COSEKey, error) {
+	decoded, _, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key", ErrWebAuthnVerification)
+	}
+
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid public key", ErrWebAuthnVerification)
+	}
+
+	keyType, _ := key[int64(1)].(int64)
+	algorithm, _ := key[int64(3)].(int64)
+
+	switch {
+	case keyType == 2 && algorithm == COSEAlgES256:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if curve != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: invalid EC2 public key", ErrWebAuthnVerification)
+		}
+
+		point := append(append([]byte{0x04}, x...), y...)
+		publicKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid EC2 public key", ErrWebAuthnVerification)
+		}
+		return &COSEKey{Algorithm: algorithm, PublicKey: publicKey}, nil
+
+	case keyType == 3 && algorithm == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: invalid RSA public key", ErrWebAuthnVerification)
+		}
+
+		publicKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if publicKey.N.BitLen() < minRSAKeyBits || publicKey.E < 3 {
+			return nil, fmt.Errorf("%w: invalid RSA public key", ErrWebAuthnVerification)
+		}
+		return &COSEKey{Algorithm: algorithm, PublicKey: publicKey}, nil
+
+	case keyType == 1 && algorithm == COSEAlgEdDSA:
+		curve, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if curve != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid OKP public key", ErrWebAuthnVerification)
+		}
+		return &COSEKey{Algorithm: algorithm, PublicKey: ed25519.PublicKey(x)}, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported public key algorithm %d", ErrWebAuthnVerification, algorithm)
+}
+
+// Verify checks a signature made by the credential's private key
+func (k *COSEKey) Verify(signed, signature []byte) error {
+	valid := false
+
+	switch publicKey := k.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		valid = ecdsa.VerifyASN1(publicKey, digest[:], signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		valid = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, signed, signature)
+	}
+
+	if !valid {
+		return fmt.Errorf("%w: invalid signature", ErrWebAuthnVerification)
+	}
+	return nil
+}
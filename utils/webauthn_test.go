@@ -0,0 +1,149 @@
+package utils_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/internal/webauthntest"
+	"github.com/mamatqurtifa/golang-auth-api-boilerplate/utils"
+)
+
+const (
+	testRPID      = "example.com"
+	testOrigin    = "https://example.com"
+	testChallenge = "AAECAwQFBgcICQoLDA0ODw"
+)
+
+func TestVerifyClientData(t *testing.T) {
+	authenticator := webauthntest.NewES256(testRPID, testOrigin)
+	origins := []string{testOrigin}
+
+	if err := utils.VerifyClientData(authenticator.ClientData(utils.WebAuthnCeremonyGet, testChallenge), utils.WebAuthnCeremonyGet, testChallenge, origins); err != nil {
+		t.Fatalf("VerifyClientData() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		clientData []byte
+	}{
+		{"wrong ceremony", authenticator.ClientData(utils.WebAuthnCeremonyCreate, testChallenge)},
+		{"wrong challenge", authenticator.ClientData(utils.WebAuthnCeremonyGet, "BBECAwQFBgcICQoLDA0ODw")},
+		{"invalid challenge", authenticator.ClientData(utils.WebAuthnCeremonyGet, "not base64url!")},
+		{"wrong origin", webauthntest.NewES256(testRPID, "https://evil.example").ClientData(utils.WebAuthnCeremonyGet, testChallenge)},
+		{"invalid JSON", []byte("{")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := utils.VerifyClientData(tt.clientData, utils.WebAuthnCeremonyGet, testChallenge, origins)
+			if !errors.Is(err, utils.ErrWebAuthnVerification) {
+				t.Errorf("VerifyClientData() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+			}
+		})
+	}
+}
+
+func TestParseAttestationObject(t *testing.T) {
+	for _, authenticator := range testAuthenticators() {
+		credential := authenticator.Create(testChallenge, "dXNlcg")
+
+		attestationObject, err := utils.DecodeBase64URL(credential.Response.AttestationObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authData, format, err := utils.ParseAttestationObject(attestationObject)
+		if err != nil {
+			t.Fatalf("ParseAttestationObject() error = %v", err)
+		}
+
+		if format != "none" {
+			t.Errorf("format = %q, want none", format)
+		}
+		if !authData.MatchesRPID(testRPID) || authData.MatchesRPID("evil.example") {
+			t.Error("MatchesRPID() did not match only the credential's relying party")
+		}
+		if !authData.UserPresent() || !authData.UserVerified() {
+			t.Error("expected user present and verified flags")
+		}
+		if string(authData.CredentialID) != string(authenticator.CredentialID) {
+			t.Errorf("CredentialID = %x, want %x", authData.CredentialID, authenticator.CredentialID)
+		}
+		if string(authData.CredentialPublicKey) != string(authenticator.PublicKeyCOSE()) {
+			t.Error("CredentialPublicKey does not match the authenticator's key")
+		}
+	}
+}
+
+func TestParseAuthenticatorDataRejectsMalformedData(t *testing.T) {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	tests := []struct {
+		name     string
+		authData []byte
+	}{
+		{"too short", rpIDHash[:]},
+		{"truncated attested data", append(append(rpIDHash[:], 0x41, 0, 0, 0, 1), make([]byte, 10)...)},
+		{"trailing data", append(rpIDHash[:], 0x01, 0, 0, 0, 1, 0xff)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := utils.ParseAuthenticatorData(tt.authData); !errors.Is(err, utils.ErrWebAuthnVerification) {
+				t.Errorf("ParseAuthenticatorData() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+			}
+		})
+	}
+}
+
+func TestCOSEKeyVerify(t *testing.T) {
+	for _, authenticator := range testAuthenticators() {
+		key, err := utils.ParseCOSEKey(authenticator.PublicKeyCOSE())
+		if err != nil {
+			t.Fatalf("ParseCOSEKey() error = %v", err)
+		}
+
+		authData := []byte("authenticator data")
+		clientData := authenticator.ClientData(utils.WebAuthnCeremonyGet, testChallenge)
+		clientDataHash := sha256.Sum256(clientData)
+		signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+		signature := authenticator.Sign(authData, clientData)
+
+		if err := key.Verify(signed, signature); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+
+		signed[0] ^= 0xff
+		if err := key.Verify(signed, signature); !errors.Is(err, utils.ErrWebAuthnVerification) {
+			t.Errorf("Verify() of tampered data error = %v, want %v", err, utils.ErrWebAuthnVerification)
+		}
+	}
+}
+
+func TestParseCOSEKeyRejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  webauthntest.Map
+	}{
+		{"unsupported algorithm", webauthntest.Map{1, 2, 3, -35, -1, 2, -2, make([]byte, 48), -3, make([]byte, 48)}},
+		{"wrong curve", webauthntest.Map{1, 2, 3, -7, -1, 2, -2, make([]byte, 32), -3, make([]byte, 32)}},
+		{"point not on curve", webauthntest.Map{1, 2, 3, -7, -1, 1, -2, make([]byte, 32), -3, make([]byte, 32)}},
+		{"short Ed25519 key", webauthntest.Map{1, 1, 3, -8, -1, 6, -2, make([]byte, 31)}},
+		{"small RSA key", webauthntest.Map{1, 3, 3, -257, -1, make([]byte, 128), -2, []byte{1, 0, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := utils.ParseCOSEKey(webauthntest.EncodeCBOR(tt.key)); !errors.Is(err, utils.ErrWebAuthnVerification) {
+				t.Errorf("ParseCOSEKey() error = %v, want %v", err, utils.ErrWebAuthnVerification)
+			}
+		})
+	}
+}
+
+func testAuthenticators() []*webauthntest.Authenticator {
+	return []*webauthntest.Authenticator{
+		webauthntest.NewES256(testRPID, testOrigin),
+		webauthntest.NewEdDSA(testRPID, testOrigin),
+	}
+}